@@ -0,0 +1,384 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AlertFormat is format of payload of webhook
+type AlertFormat int8
+
+const (
+	// AlertGeneric posts JSON with service, host, level, message, caller, suppressed & text of alert
+	AlertGeneric AlertFormat = iota
+	// AlertSlack posts {"text": ...} to incoming webhook of Slack (Mattermost, Rocket.Chat)
+	AlertSlack
+	// AlertTelegram posts {"chat_id": ..., "text": ...} to sendMessage method of Telegram bot
+	AlertTelegram
+)
+
+// alertDigestSize is max count of suppressed alerts listed in the next alert
+const alertDigestSize = 5
+
+// AlertWriter posts compact alerts about ERROR & CRITICAL records to chat webhook.
+// Identical alerts are suppressed within dedup window, alerts over rate limit are suppressed too,
+// counts of suppressed alerts are reported by the next alert
+type AlertWriter struct {
+	url        string
+	format     AlertFormat
+	chatID     string
+	service    string
+	host       string
+	level      Level
+	window     time.Duration
+	rateCount  int
+	ratePeriod time.Duration
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+	now        func() time.Time
+
+	lock sync.Mutex
+	// sentAt is time of last alert of every key
+	sentAt map[string]time.Time
+	// suppressed are alerts which weren't sent since last alert
+	suppressed map[string]*suppressedAlert
+	// recent is times of alerts within rate period
+	recent []time.Time
+}
+
+// suppressedAlert is counter of suppressed identical alerts
+type suppressedAlert struct {
+	title string
+	count int
+	first time.Time
+}
+
+// AlertOption configures AlertWriter
+type AlertOption func(*AlertWriter)
+
+// AlertSlackFormat posts alerts to incoming webhook of Slack
+func AlertSlackFormat() AlertOption {
+	return func(w *AlertWriter) {
+		w.format = AlertSlack
+	}
+}
+
+// AlertTelegramChat posts alerts to chat of Telegram bot, url is 'https://api.telegram.org/bot<token>/sendMessage'
+func AlertTelegramChat(chatID string) AlertOption {
+	return func(w *AlertWriter) {
+		w.format, w.chatID = AlertTelegram, chatID
+	}
+}
+
+// AlertService sets name of service in alerts, name of executable by default
+func AlertService(name string) AlertOption {
+	return func(w *AlertWriter) {
+		w.service = name
+	}
+}
+
+// AlertHost sets host in alerts, os.Hostname() by default
+func AlertHost(host string) AlertOption {
+	return func(w *AlertWriter) {
+		w.host = host
+	}
+}
+
+// AlertLevel sets the least important level of alerts, ERROR by default (custom levels use their severity)
+func AlertLevel(level Level) AlertOption {
+	return func(w *AlertWriter) {
+		w.level = level
+	}
+}
+
+// AlertDedupWindow sets period of suppressing of identical alerts (level, message without numbers & caller), 5 minutes by default
+func AlertDedupWindow(window time.Duration) AlertOption {
+	return func(w *AlertWriter) {
+		w.window = window
+	}
+}
+
+// AlertRateLimit sets max count of alerts per period, 10 per minute by default
+func AlertRateLimit(count int, period time.Duration) AlertOption {
+	return func(w *AlertWriter) {
+		w.rateCount, w.ratePeriod = count, period
+	}
+}
+
+// AlertClient sets http client of requests, http.DefaultClient by default
+func AlertClient(client *http.Client) AlertOption {
+	return func(w *AlertWriter) {
+		w.client = client
+	}
+}
+
+// AlertRetries sets count of repeats of failed requests & pause before first repeat, it doubles for next ones
+func AlertRetries(retries int, delay time.Duration) AlertOption {
+	return func(w *AlertWriter) {
+		w.retries, w.retryDelay = retries, delay
+	}
+}
+
+// NewAlertWriter creates writer posting alerts to webhook at url,
+// ex. logs.AddWriter(logs.NewAlertWriter(slackURL, logs.AlertSlackFormat()))
+func NewAlertWriter(url string, opts ...AlertOption) *AlertWriter {
+	w := &AlertWriter{
+		url:        url,
+		service:    filepath.Base(os.Args[0]),
+		level:      ERROR,
+		window:     5 * time.Minute,
+		rateCount:  10,
+		ratePeriod: time.Minute,
+		client:     http.DefaultClient,
+		retries:    2,
+		retryDelay: time.Second,
+		now:        time.Now,
+		sentAt:     make(map[string]time.Time),
+		suppressed: make(map[string]*suppressedAlert),
+	}
+	w.host, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Write posts p as message of INFO record, it is skipped unless level of alerts is INFO or less important
+func (w *AlertWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord posts alert about rec or suppresses it, records less important than level of alerts are skipped
+func (w *AlertWriter) WriteRecord(rec *Record) error {
+	if rec.Level.Severity() > w.level.Severity() {
+		return nil
+	}
+
+	title, key := w.title(rec)
+
+	w.lock.Lock()
+	now := w.now()
+	w.forget(now)
+
+	if sentAt, ok := w.sentAt[key]; ok && now.Sub(sentAt) < w.window || len(w.recent) >= w.rateCount {
+		w.suppress(key, title, now)
+		w.lock.Unlock()
+		return nil
+	}
+
+	// alert is counted before posting, so concurrent alerts are deduplicated & limited while it is posted
+	text, count := w.text(rec, title), w.suppressedCount()
+	suppressed := w.suppressed
+	w.suppressed = make(map[string]*suppressedAlert)
+	w.sentAt[key] = now
+	w.recent = append(w.recent, now)
+	w.lock.Unlock()
+
+	err := w.post(rec, text, count)
+	if err == nil {
+		return nil
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.unsent(key, title, now, suppressed)
+
+	return errors.Wrap(err, "post alert")
+}
+
+// unsent cancels counting of alert with key & title which wasn't posted at now,
+// the alert & its digest of suppressed alerts are reported by the next alert
+func (w *AlertWriter) unsent(key, title string, now time.Time, suppressed map[string]*suppressedAlert) {
+	if w.sentAt[key].Equal(now) {
+		delete(w.sentAt, key)
+	}
+	if i := slices.IndexFunc(w.recent, now.Equal); i >= 0 {
+		w.recent = slices.Delete(w.recent, i, i+1)
+	}
+
+	for k, s := range suppressed {
+		if cur, ok := w.suppressed[k]; ok {
+			s.count += cur.count
+		}
+		w.suppressed[k] = s
+	}
+	w.suppress(key, title, now)
+}
+
+// title returns title of alert about rec (level, message & caller) & key of identical alerts,
+// numbers are replaced in message of key, so alerts differing by ids are grouped as MailDigestWriter does it
+func (w *AlertWriter) title(rec *Record) (string, string) {
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+	msg = strings.TrimSpace(stripColors(msg))
+
+	caller := ""
+	if frame := alertFrame(rec); frame.File > "" {
+		caller = " (" + frame.String() + ")"
+	}
+	level := rec.Level.String() + " "
+
+	return level + msg + caller, level + digestNumbers.ReplaceAllString(msg, "#") + caller
+}
+
+// alertFrame returns top frame of stack of rec (frames are already filtered with ignored files & functions)
+// or caller of record
+func alertFrame(rec *Record) StackFrame {
+	if len(rec.Stack) > 0 {
+		return rec.Stack[0]
+	}
+
+	return StackFrame{File: rec.File, Line: rec.Line, Func: rec.Func}
+}
+
+// forget removes dedup keys & times of rate limit which are expired at now
+func (w *AlertWriter) forget(now time.Time) {
+	for key, sentAt := range w.sentAt {
+		if now.Sub(sentAt) >= w.window {
+			delete(w.sentAt, key)
+		}
+	}
+
+	i := 0
+	for i < len(w.recent) && now.Sub(w.recent[i]) >= w.ratePeriod {
+		i++
+	}
+	w.recent = w.recent[i:]
+}
+
+func (w *AlertWriter) suppress(key, title string, now time.Time) {
+	s, ok := w.suppressed[key]
+	if !ok {
+		s = &suppressedAlert{title: title, first: now}
+		w.suppressed[key] = s
+	}
+	s.count++
+}
+
+// suppressedCount returns count of alerts suppressed since last alert
+func (w *AlertWriter) suppressedCount() int {
+	count := 0
+	for _, s := range w.suppressed {
+		count += s.count
+	}
+
+	return count
+}
+
+// text returns text of alert about rec with digest of suppressed alerts
+func (w *AlertWriter) text(rec *Record, title string) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "[%s] %s@%s\n", rec.Level, w.service, w.host)
+
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+	b.WriteString(strings.TrimSpace(stripColors(msg)))
+	if rec.Err != nil && !strings.Contains(msg, rec.Err.Error()) {
+		b.WriteString(": " + rec.Err.Error())
+	}
+	if frame := alertFrame(rec); frame.File > "" {
+		b.WriteString("\nat " + frame.String())
+	}
+
+	if len(w.suppressed) == 0 {
+		return b.String()
+	}
+
+	digest := make([]*suppressedAlert, 0, len(w.suppressed))
+	for _, s := range w.suppressed {
+		digest = append(digest, s)
+	}
+	sort.Slice(digest, func(i, j int) bool {
+		if digest[i].count != digest[j].count {
+			return digest[i].count > digest[j].count
+		}
+		return digest[i].first.Before(digest[j].first)
+	})
+
+	fmt.Fprintf(b, "\nsuppressed %d alerts since last one:", w.suppressedCount())
+	for i, s := range digest {
+		if i == alertDigestSize {
+			fmt.Fprintf(b, "\n... and %d more", len(digest)-alertDigestSize)
+			break
+		}
+		fmt.Fprintf(b, "\n%d× %s", s.count, s.title)
+	}
+
+	return b.String()
+}
+
+// post sends alert text with count of suppressed alerts in format of webhook
+func (w *AlertWriter) post(rec *Record, text string, suppressed int) error {
+	body := &bytes.Buffer{}
+	switch w.format {
+	case AlertSlack:
+		body.WriteString(`{"text":`)
+		body.Write(jsonValue(text))
+		body.WriteByte('}')
+	case AlertTelegram:
+		body.WriteString(`{"chat_id":`)
+		body.Write(jsonValue(w.chatID))
+		body.WriteString(`,"text":`)
+		body.Write(jsonValue(text))
+		body.WriteByte('}')
+	default:
+		msg := rec.Message
+		if msg == "" {
+			msg = string(rec.text)
+		}
+		body.WriteString(`{"service":`)
+		body.Write(jsonValue(w.service))
+		body.WriteString(`,"host":`)
+		body.Write(jsonValue(w.host))
+		body.WriteString(`,"level":`)
+		body.Write(jsonValue(rec.Level.String()))
+		body.WriteString(`,"message":`)
+		body.Write(jsonValue(strings.TrimSpace(stripColors(msg))))
+		if frame := alertFrame(rec); frame.File > "" {
+			body.WriteString(`,"caller":`)
+			body.Write(jsonValue(frame))
+		}
+		body.WriteString(`,"suppressed":`)
+		body.Write(jsonValue(suppressed))
+		body.WriteString(`,"text":`)
+		body.Write(jsonValue(text))
+		body.WriteByte('}')
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), fatalTimeout)
+	defer cancel()
+
+	return retry(ctx, w.retries, w.retryDelay, func() error {
+		return postRequest(ctx, w.client, w.url, header, body.Bytes())
+	})
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookServer is stand-in of chat webhook, it keeps payloads of alerts
+type webhookServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	payloads []map[string]any
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+	s := &webhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.payloads = append(s.payloads, payload)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestAlertWriter(t *testing.T) {
+	s := newWebhookServer(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewAlertWriter(s.URL, AlertService("api"), AlertHost("host"), AlertDedupWindow(time.Minute))
+	w.now = func() time.Time { return now }
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	for range 3 {
+		l.ErrorLog(errors.New("db is down"))
+	}
+	l.WarningLog("not an alert")
+	require.NoError(t, l.Flush(t.Context()))
+
+	require.Len(t, s.payloads, 1)
+	alert := s.payloads[0]
+	assert.Equal(t, "api", alert["service"])
+	assert.Equal(t, "host", alert["host"])
+	assert.Equal(t, "ERROR", alert["level"])
+	assert.Contains(t, alert["message"], "db is down")
+	assert.Regexp(t, `^alert_test.go:\d+ TestAlertWriter\(\)$`, alert["caller"])
+	assert.Equal(t, float64(0), alert["suppressed"])
+	assert.Contains(t, alert["text"], "[ERROR] api@host\n")
+
+	now = now.Add(time.Minute)
+	require.NoError(t, w.WriteRecord(&Record{Level: CRITICAL, Message: "disk is full", File: "main.go", Line: 7, Func: "main.run"}))
+
+	require.Len(t, s.payloads, 2)
+	assert.Equal(t, float64(2), s.payloads[1]["suppressed"])
+	assert.Equal(t, "[CRITICAL] api@host\ndisk is full\nat main.go:7 main.run()\n"+
+		"suppressed 2 alerts since last one:\n2× ERROR "+alert["message"].(string)+" ("+alert["caller"].(string)+")",
+		s.payloads[1]["text"])
+}
+
+func TestAlertRateLimit(t *testing.T) {
+	s := newWebhookServer(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewAlertWriter(s.URL, AlertSlackFormat(), AlertService("api"), AlertHost("host"),
+		AlertRateLimit(2, time.Minute))
+	w.now = func() time.Time { return now }
+
+	for _, msg := range []string{"first", "second", "third", "fourth", "third"} {
+		require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: msg}))
+	}
+	require.Len(t, s.payloads, 2)
+	assert.Equal(t, map[string]any{"text": "[ERROR] api@host\nsecond"}, s.payloads[1])
+
+	now = now.Add(time.Minute)
+	require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: "fifth"}))
+	require.Len(t, s.payloads, 3)
+	assert.Equal(t, "[ERROR] api@host\nfifth\nsuppressed 3 alerts since last one:\n2× ERROR third\n1× ERROR fourth",
+		s.payloads[2]["text"])
+}
+
+func TestAlertDedupNumbers(t *testing.T) {
+	s := newWebhookServer(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewAlertWriter(s.URL, AlertSlackFormat(), AlertService("api"), AlertHost("host"))
+	w.now = func() time.Time { return now }
+
+	for _, msg := range []string{"order 17 failed", "order 42 failed", "order 42 failed twice"} {
+		require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: msg, File: "order.go", Line: 12}))
+	}
+	require.Len(t, s.payloads, 2)
+	assert.Equal(t, "[ERROR] api@host\norder 17 failed\nat order.go:12 ()", s.payloads[0]["text"])
+	assert.Equal(t, "[ERROR] api@host\norder 42 failed twice\nat order.go:12 ()\n"+
+		"suppressed 1 alerts since last one:\n1× ERROR order 42 failed (order.go:12 ())", s.payloads[1]["text"])
+
+	now = now.Add(time.Hour)
+	require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: "order 7 failed", File: "order.go", Line: 12}))
+	assert.Len(t, s.payloads, 3)
+}
+
+func TestAlertTelegram(t *testing.T) {
+	s := newWebhookServer(t)
+	w := NewAlertWriter(s.URL, AlertTelegramChat("-100"), AlertService("api"), AlertHost("host"), AlertLevel(WARNING))
+
+	_, err := w.Write([]byte("skipped"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRecord(&Record{Level: WARNING, Message: "slow", Err: errors.New("timeout")}))
+
+	require.Len(t, s.payloads, 1)
+	assert.Equal(t, map[string]any{"chat_id": "-100", "text": "[WARNING] api@host\nslow: timeout"}, s.payloads[0])
+}
+
+func TestAlertPostUnlocked(t *testing.T) {
+	started, gate := make(chan struct{}), make(chan struct{})
+	var (
+		lock     sync.Mutex
+		calls    int
+		payloads []map[string]any
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		calls++
+		first := calls == 1
+		lock.Unlock()
+
+		// the first alert is posted slowly & fails
+		if first {
+			close(started)
+			<-gate
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var payload map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		lock.Lock()
+		defer lock.Unlock()
+		payloads = append(payloads, payload)
+	}))
+	t.Cleanup(s.Close)
+
+	w := NewAlertWriter(s.URL, AlertSlackFormat(), AlertService("api"), AlertHost("host"), AlertRetries(0, 0))
+	posted := make(chan error)
+	go func() {
+		posted <- w.WriteRecord(&Record{Level: ERROR, Message: "db is down"})
+	}()
+	<-started
+
+	// identical alert is suppressed without waiting for posting of the first one
+	start := time.Now()
+	require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: "db is down"}))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	close(gate)
+	assert.ErrorContains(t, <-posted, "post alert")
+
+	// failed alert is reported by the next one
+	require.NoError(t, w.WriteRecord(&Record{Level: CRITICAL, Message: "disk is full"}))
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, payloads, 1)
+	assert.Equal(t, "[CRITICAL] api@host\ndisk is full\nsuppressed 2 alerts since last one:\n2× ERROR db is down",
+		payloads[0]["text"])
+}
@@ -0,0 +1,269 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BatchOptions configures batching of records by writers which send them to remote services.
+// Batch which isn't sent after retries of writer is dropped, its error is returned by the next write or Flush
+// & its records are counted by Dropped method of writer
+type BatchOptions struct {
+	// MaxCount is count of records which triggers sending, 500 by default
+	MaxCount int
+	// MaxBytes is size of encoded records which triggers sending, 5 MB by default
+	MaxBytes int
+	// Interval is max time of keeping record in batch, 5 seconds by default
+	Interval time.Duration
+}
+
+var defaultBatchOptions = BatchOptions{MaxCount: 500, MaxBytes: 5 << 20, Interval: 5 * time.Second}
+
+// batchItem is record with its encoded form
+type batchItem struct {
+	rec  *Record
+	data []byte
+}
+
+// batcher collects records & sends them when batch is full or interval is over
+type batcher struct {
+	opts BatchOptions
+	send func(ctx context.Context, items []batchItem) error
+
+	lock  sync.Mutex
+	items []batchItem
+	size  int
+	timer *time.Timer
+	// err is error of sending by timer, it is returned by next add or flush
+	err error
+	// sendLock keeps order of batches
+	sendLock sync.Mutex
+	// dropped is count of records of failed batches
+	dropped atomic.Uint64
+}
+
+func newBatcher(opts BatchOptions, send func(ctx context.Context, items []batchItem) error) *batcher {
+	if opts.MaxCount <= 0 {
+		opts.MaxCount = defaultBatchOptions.MaxCount
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultBatchOptions.MaxBytes
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultBatchOptions.Interval
+	}
+
+	return &batcher{opts: opts, send: send}
+}
+
+// add appends rec to batch, full batch is sent at once
+func (b *batcher) add(rec *Record, data []byte) error {
+	b.lock.Lock()
+	b.items = append(b.items, batchItem{rec, data})
+	b.size += len(data)
+	full := len(b.items) >= b.opts.MaxCount || b.size >= b.opts.MaxBytes
+	if !full && b.timer == nil {
+		b.timer = time.AfterFunc(b.opts.Interval, b.flushByTimer)
+	}
+	err := b.err
+	b.err = nil
+	b.lock.Unlock()
+
+	if full {
+		if errSend := b.sendBatch(context.Background()); errSend != nil {
+			return errSend
+		}
+	}
+
+	return err
+}
+
+// flush sends collected records, returns error of sending or error of previous sending by timer
+func (b *batcher) flush(ctx context.Context) error {
+	err := b.sendBatch(ctx)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err == nil {
+		err = b.err
+	}
+	b.err = nil
+
+	return err
+}
+
+func (b *batcher) flushByTimer() {
+	if err := b.sendBatch(context.Background()); err != nil {
+		b.lock.Lock()
+		b.err = err
+		b.lock.Unlock()
+	}
+}
+
+func (b *batcher) sendBatch(ctx context.Context) error {
+	b.sendLock.Lock()
+	defer b.sendLock.Unlock()
+
+	b.lock.Lock()
+	items := b.items
+	b.items, b.size = nil, 0
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.lock.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	err := b.send(ctx, items)
+	if err != nil {
+		count := len(items)
+		var partial partialError
+		if errors.As(err, &partial) {
+			count = partial.count
+		}
+		b.dropped.Add(uint64(count))
+	}
+
+	return err
+}
+
+// partialError is failure of sending of count records of batch, the other records are delivered
+type partialError struct {
+	error
+	count int
+}
+
+func (e partialError) Unwrap() error {
+	return e.error
+}
+
+// httpStatusError is unexpected status of response of remote service
+type httpStatusError struct {
+	code int
+	body string
+}
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.code, e.body)
+}
+
+// checkResponse returns httpStatusError with beginning of body for unsuccessful response
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	return httpStatusError{resp.StatusCode, string(body)}
+}
+
+// retryableStatus reports whether request with status may succeed later
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// permanentError is failure which doesn't disappear on repeat of request
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// retryable reports whether request failed with err may be repeated
+func retryable(err error) bool {
+	var statusErr httpStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.code)
+	}
+	if errors.As(err, &permanentError{}) {
+		return false
+	}
+
+	return err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// retry calls fn until it succeeds, fails with not retryable error, ctx is done or retries are over,
+// delay before repeat doubles after every attempt
+func retry(ctx context.Context, retries int, delay time.Duration, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !retryable(err) || attempt >= retries {
+			return err
+		}
+
+		if waitRetry(ctx, delay) != nil {
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// postRequest posts body to url with header, returns httpStatusError for unsuccessful response
+func postRequest(ctx context.Context, client *http.Client, url string, header http.Header, body []byte) error {
+	return postJSON(ctx, client, url, header, body, nil)
+}
+
+// postJSON posts body to url with header & decodes JSON response to result if it isn't nil,
+// returns httpStatusError for unsuccessful response
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, body []byte, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if result != nil {
+		return errors.Wrap(json.NewDecoder(resp.Body).Decode(result), "decode response")
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// basicAuth returns value of Authorization header for user & password
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// waitRetry waits delay before next attempt or until ctx is done
+func waitRetry(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -22,42 +22,70 @@ const (
 	NOTICE
 	INFO
 	DEBUG
+	TRACE
 )
 
-type color int
+// Color is ANSI color code of level output
+type Color int
 
 const (
-	colorBlack = (iota + 30)
-	colorRed
-	colorGreen
-	colorYellow
-	colorBlue
-	colorMagenta
-	colorCyan
-	colorWhite
+	ColorBlack Color = (iota + 30)
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
 )
 
 var (
 	colors = []string{
-		CRITICAL: colorSeq(colorMagenta),
-		ERROR:    colorSeq(colorRed),
-		WARNING:  colorSeq(colorYellow),
-		NOTICE:   colorSeq(colorGreen),
-		DEBUG:    colorSeq(colorCyan),
+		CRITICAL: colorSeq(ColorMagenta),
+		ERROR:    colorSeq(ColorRed),
+		WARNING:  colorSeq(ColorYellow),
+		NOTICE:   colorSeq(ColorGreen),
+		INFO:     "",
+		DEBUG:    colorSeq(ColorCyan),
+		TRACE:    colorSeq(ColorBlue),
 	}
 	boldcolors = []string{
-		CRITICAL: colorSeqBold(colorMagenta),
-		ERROR:    colorSeqBold(colorRed),
-		WARNING:  colorSeqBold(colorYellow),
-		NOTICE:   colorSeqBold(colorGreen),
-		DEBUG:    colorSeqBold(colorCyan),
+		CRITICAL: colorSeqBold(ColorMagenta),
+		ERROR:    colorSeqBold(ColorRed),
+		WARNING:  colorSeqBold(ColorYellow),
+		NOTICE:   colorSeqBold(ColorGreen),
+		INFO:     "",
+		DEBUG:    colorSeqBold(ColorCyan),
+		TRACE:    colorSeqBold(ColorBlue),
 	}
 )
 
-func colorSeq(color color) string {
+func colorSeq(color Color) string {
 	return fmt.Sprintf(LogPutColor+"%dm", int(color))
 }
 
-func colorSeqBold(color color) string {
+func colorSeqBold(color Color) string {
 	return fmt.Sprintf(LogPutColor+"%d;1m", int(color))
 }
+
+func levelColor(level Level) string {
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+
+	if level < 0 || int(level) >= len(colors) {
+		return ""
+	}
+
+	return colors[level]
+}
+
+func levelBoldColor(level Level) string {
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+
+	if level < 0 || int(level) >= len(boldcolors) {
+		return ""
+	}
+
+	return boldcolors[level]
+}
@@ -0,0 +1,250 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ElasticWriter sends records to Elasticsearch with bulk API as documents of JSONEncoder,
+// items rejected with 429 or 5xx statuses are retried
+type ElasticWriter struct {
+	url        string
+	index      string
+	user       string
+	password   string
+	apiKey     string
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+	batch      *batcher
+}
+
+// ElasticOption configures ElasticWriter
+type ElasticOption func(*ElasticWriter)
+
+// ElasticIndex sets pattern of index name, '%{+layout}' is replaced with time of record in layout of time.Format,
+// 'logs-%{+2006.01.02}' by default
+func ElasticIndex(pattern string) ElasticOption {
+	return func(w *ElasticWriter) {
+		w.index = pattern
+	}
+}
+
+// ElasticBasicAuth sets user & password of requests
+func ElasticBasicAuth(user, password string) ElasticOption {
+	return func(w *ElasticWriter) {
+		w.user, w.password = user, password
+	}
+}
+
+// ElasticAPIKey sets API key (base64 encoded 'id:key') of requests
+func ElasticAPIKey(key string) ElasticOption {
+	return func(w *ElasticWriter) {
+		w.apiKey = key
+	}
+}
+
+// ElasticClient sets http client of requests, http.DefaultClient by default
+func ElasticClient(client *http.Client) ElasticOption {
+	return func(w *ElasticWriter) {
+		w.client = client
+	}
+}
+
+// ElasticRetries sets count of repeats of failed items & pause before first repeat, it doubles for next ones
+func ElasticRetries(retries int, delay time.Duration) ElasticOption {
+	return func(w *ElasticWriter) {
+		w.retries, w.retryDelay = retries, delay
+	}
+}
+
+// ElasticBatch sets options of batches of records
+func ElasticBatch(opts BatchOptions) ElasticOption {
+	return func(w *ElasticWriter) {
+		w.batch = newBatcher(opts, w.send)
+	}
+}
+
+// NewElasticWriter creates writer to Elasticsearch at url (ex. 'http://localhost:9200'),
+// records are sent by batches, call Flush or Logger.Shutdown to send the rest of records
+func NewElasticWriter(url string, opts ...ElasticOption) *ElasticWriter {
+	w := &ElasticWriter{
+		url:        strings.TrimSuffix(url, "/"),
+		index:      "logs-%{+2006.01.02}",
+		client:     http.DefaultClient,
+		retries:    3,
+		retryDelay: time.Second,
+	}
+	w.batch = newBatcher(defaultBatchOptions, w.send)
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Write adds p as message of INFO record to batch
+func (w *ElasticWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord adds rec to batch, returns error of sending of full batch or of previous batch
+func (w *ElasticWriter) WriteRecord(rec *Record) error {
+	b := &bytes.Buffer{}
+	b.WriteString(`{"create":{"_index":`)
+	b.Write(jsonValue(w.indexName(rec.Time)))
+	b.WriteString("}}\n")
+	if err := (JSONEncoder{}).Encode(b, rec); err != nil {
+		return err
+	}
+
+	return w.batch.add(rec, b.Bytes())
+}
+
+// Flush sends collected records
+func (w *ElasticWriter) Flush(ctx context.Context) error {
+	return w.batch.flush(ctx)
+}
+
+// Dropped returns count of records of batches which weren't sent after retries
+func (w *ElasticWriter) Dropped() uint64 {
+	return w.batch.dropped.Load()
+}
+
+// Close sends collected records
+func (w *ElasticWriter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalTimeout)
+	defer cancel()
+
+	return w.batch.flush(ctx)
+}
+
+// indexName returns name of index for records of time t
+func (w *ElasticWriter) indexName(t time.Time) string {
+	name := w.index
+	for {
+		start := strings.Index(name, "%{+")
+		if start < 0 {
+			return name
+		}
+
+		end := strings.IndexByte(name[start:], '}')
+		if end < 0 {
+			return name
+		}
+
+		end += start
+		name = name[:start] + t.UTC().Format(name[start+3:end]) + name[end+1:]
+	}
+}
+
+// send posts items with retries of failed items
+func (w *ElasticWriter) send(ctx context.Context, items []batchItem) error {
+	rejected := make([]string, 0)
+	delay := w.retryDelay
+	for attempt := 0; ; attempt++ {
+		failed, reasons, err := w.post(ctx, items)
+		rejected = append(rejected, reasons...)
+
+		switch {
+		case err != nil && !retryable(err):
+			return partialError{errors.Wrap(err, "elastic bulk"), len(rejected) + len(items)}
+		case err != nil:
+			failed = items
+		case len(failed) == 0 && len(rejected) > 0:
+			return partialError{
+				errors.Errorf("elastic rejected %d records: %s", len(rejected), strings.Join(rejected, "; ")),
+				len(rejected),
+			}
+		case len(failed) == 0:
+			return nil
+		}
+
+		if attempt >= w.retries {
+			if err == nil {
+				err = errors.Errorf("%d records weren't indexed", len(failed))
+			}
+			return partialError{errors.Wrapf(err, "elastic bulk after %d retries", attempt), len(rejected) + len(failed)}
+		}
+
+		if errWait := waitRetry(ctx, delay); errWait != nil {
+			return partialError{
+				errors.Wrapf(errWait, "elastic bulk, %d records weren't indexed", len(failed)),
+				len(rejected) + len(failed),
+			}
+		}
+		delay *= 2
+		items = failed
+	}
+}
+
+// bulkResponse is response of bulk API, items contain results of actions with records
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// header returns headers of bulk requests
+func (w *ElasticWriter) header() http.Header {
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-ndjson")
+	switch {
+	case w.apiKey > "":
+		header.Set("Authorization", "ApiKey "+w.apiKey)
+	case w.user > "":
+		header.Set("Authorization", basicAuth(w.user, w.password))
+	}
+
+	return header
+}
+
+// post sends items, returns items to retry & reasons of rejection of other failed items
+func (w *ElasticWriter) post(ctx context.Context, items []batchItem) ([]batchItem, []string, error) {
+	body := &bytes.Buffer{}
+	for _, item := range items {
+		body.Write(item.data)
+	}
+
+	var result bulkResponse
+	if err := postJSON(ctx, w.client, w.url+"/_bulk", w.header(), body.Bytes(), &result); err != nil {
+		return nil, nil, err
+	}
+	if !result.Errors {
+		return nil, nil, nil
+	}
+
+	failed, rejected := make([]batchItem, 0), make([]string, 0)
+	for i, item := range result.Items {
+		for _, res := range item {
+			switch {
+			case i >= len(items) || res.Status < 300:
+			case retryableStatus(res.Status):
+				failed = append(failed, items[i])
+			default:
+				rejected = append(rejected, res.Error.Type+": "+res.Error.Reason)
+			}
+		}
+	}
+
+	return failed, rejected, nil
+}
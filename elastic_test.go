@@ -0,0 +1,154 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bulkServer is stand-in of bulk API, it answers with statuses of items from queue of responses
+type bulkServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	requests [][]map[string]any
+	statuses [][]int
+	auth     []string
+}
+
+func newBulkServer(t *testing.T, statuses ...[]int) *bulkServer {
+	s := &bulkServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/_bulk", r.URL.Path)
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+
+		lines := make([]map[string]any, 0)
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line map[string]any
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		s.requests = append(s.requests, lines)
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		var codes []int
+		if len(s.statuses) > 0 {
+			codes, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		if len(codes) == 1 && codes[0] >= 300 && len(lines) > 2 {
+			w.WriteHeader(codes[0])
+			return
+		}
+
+		items := make([]string, 0)
+		for i := range len(lines) / 2 {
+			code := http.StatusCreated
+			if i < len(codes) {
+				code = codes[i]
+			}
+			items = append(items, fmt.Sprintf(`{"create":{"status":%d,"error":{"type":"err_%d","reason":"failed"}}}`, code, code))
+		}
+		_, _ = fmt.Fprintf(w, `{"errors":%v,"items":[%s]}`, len(codes) > 0, strings.Join(items, ","))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestElasticWriter(t *testing.T) {
+	s := newBulkServer(t)
+	w := NewElasticWriter(s.URL+"/", ElasticIndex("app-%{+2006.01}-logs"), ElasticBasicAuth("user", "pass"),
+		ElasticBatch(BatchOptions{MaxCount: 2, Interval: time.Hour}))
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	l.ErrorLog(fakeErr{}, "first", F("user", 7))
+	l.StatusLog("second")
+	l.StatusLog("third")
+	require.NoError(t, l.Flush(context.Background()))
+
+	require.Len(t, s.requests, 2)
+	first := s.requests[0]
+	require.Len(t, first, 4)
+	assert.Equal(t, map[string]any{"create": map[string]any{"_index": "app-" + time.Now().UTC().Format("2006.01") + "-logs"}}, first[0])
+	assert.Equal(t, "ERROR", first[1]["level"])
+	assert.Equal(t, float64(7), first[1]["user"])
+	assert.Equal(t, "fake error", first[1]["error"])
+	assert.NotEmpty(t, first[1]["@timestamp"])
+	assert.Equal(t, "second", first[3]["message"])
+	assert.Len(t, s.requests[1], 2)
+	assert.Equal(t, basicAuth("user", "pass"), s.auth[0])
+}
+
+func TestElasticRetries(t *testing.T) {
+	s := newBulkServer(t, []int{503}, []int{201, 429, 400}, []int{500})
+	w := NewElasticWriter(s.URL, ElasticAPIKey("key"), ElasticRetries(3, time.Millisecond))
+
+	for _, msg := range []string{"created", "retried", "rejected"} {
+		require.NoError(t, w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: msg}))
+	}
+	err := w.Flush(context.Background())
+	assert.ErrorContains(t, err, "elastic rejected 1 records: err_400: failed")
+	assert.Equal(t, uint64(1), w.Dropped())
+
+	require.Len(t, s.requests, 4)
+	assert.Len(t, s.requests[0], 6)
+	assert.Len(t, s.requests[1], 6)
+	assert.Equal(t, "retried", s.requests[2][1]["message"])
+	assert.Equal(t, "retried", s.requests[3][1]["message"])
+	assert.Equal(t, "ApiKey key", s.auth[0])
+
+	s.lock.Lock()
+	s.statuses = [][]int{{401}}
+	s.lock.Unlock()
+	require.NoError(t, w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: "a"}))
+	require.NoError(t, w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: "b"}))
+	assert.ErrorContains(t, w.Close(), "status 401")
+	assert.Len(t, s.requests, 5)
+	assert.Equal(t, uint64(3), w.Dropped())
+}
+
+func TestBatcherInterval(t *testing.T) {
+	sent := make(chan int, 10)
+	b := newBatcher(BatchOptions{Interval: 10 * time.Millisecond}, func(_ context.Context, items []batchItem) error {
+		sent <- len(items)
+		return fmt.Errorf("failed")
+	})
+
+	require.NoError(t, b.add(&Record{}, nil))
+	require.NoError(t, b.add(&Record{}, nil))
+	select {
+	case n := <-sent:
+		assert.Equal(t, 2, n)
+	case <-time.After(time.Second):
+		t.Fatal("batch wasn't sent by timer")
+	}
+
+	assert.Eventually(t, func() bool {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		return b.err != nil
+	}, time.Second, time.Millisecond)
+	assert.EqualError(t, b.flush(context.Background()), "failed")
+	assert.Equal(t, uint64(2), b.dropped.Load())
+	assert.NoError(t, b.flush(context.Background()))
+}
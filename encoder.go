@@ -0,0 +1,206 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Encoder renders records for console & writers of Logger
+type Encoder interface {
+	Encode(buf *bytes.Buffer, rec *Record) error
+}
+
+// TextEncoder renders records as text lines (time file:line message key=value),
+// it is default for writers
+type TextEncoder struct{}
+
+// Encode writes text of rec to buf
+func (TextEncoder) Encode(buf *bytes.Buffer, rec *Record) error {
+	_, err := buf.Write(rec.text)
+
+	return err
+}
+
+// JSONEncoder renders records as JSON objects, one per line, without color sequences.
+// Fields of record are rendered as properties of the object
+type JSONEncoder struct{}
+
+// Encode writes rec as JSON line to buf
+func (JSONEncoder) Encode(buf *bytes.Buffer, rec *Record) error {
+	b, err := json.Marshal(newRecordMess(rec))
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	buf.Write(b)
+	buf.WriteByte('\n')
+
+	return nil
+}
+
+// LogfmtEncoder renders records as logfmt lines:
+// ts=2006-01-02T15:04:05.999Z07:00 level=error caller=writer.go:123 func=ErrorLog msg="..." key=value
+type LogfmtEncoder struct {
+	// TimeFormat of 'ts', time.RFC3339Nano by default
+	TimeFormat string
+}
+
+// Encode writes rec as logfmt line to buf
+func (e LogfmtEncoder) Encode(buf *bytes.Buffer, rec *Record) error {
+	timeFormat := e.TimeFormat
+	if timeFormat == "" {
+		timeFormat = time.RFC3339Nano
+	}
+
+	buf.WriteString("ts=")
+	buf.WriteString(quoteValue(rec.Time.Format(timeFormat)))
+	buf.WriteString(" level=")
+	buf.WriteString(quoteValue(strings.ToLower(rec.Level.String())))
+	if rec.File > "" {
+		buf.WriteString(" caller=")
+		buf.WriteString(quoteValue(fmt.Sprintf("%s:%d", rec.File, rec.Line)))
+	}
+	if rec.Func > "" {
+		buf.WriteString(" func=")
+		buf.WriteString(quoteValue(rec.Func))
+	}
+	buf.WriteString(" msg=")
+	buf.WriteString(quoteValue(stripColors(rec.Message)))
+	if rec.Err != nil {
+		buf.WriteString(" error=")
+		buf.WriteString(quoteValue(rec.Err.Error()))
+	}
+
+	for _, f := range rec.Fields {
+		buf.WriteByte(' ')
+		buf.WriteString(logfmtKey(f.Key))
+		buf.WriteByte('=')
+		buf.WriteString(quoteValue(fieldValue(f.Value)))
+	}
+	buf.WriteByte('\n')
+
+	return nil
+}
+
+// logfmtKey replaces symbols which are not allowed in keys of logfmt
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+
+	return strings.Map(func(r rune) rune {
+		if needsQuote(r) {
+			return '_'
+		}
+
+		return r
+	}, key)
+}
+
+// encode renders rec with enc
+func encode(enc Encoder, rec *Record) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, len(rec.text)+64))
+	if err := enc.Encode(buf, rec); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// reservedKeys are properties of logMess which fields may not replace
+var reservedKeys = map[string]bool{
+	"message":     true,
+	"@timestamp":  true,
+	"level":       true,
+	"file":        true,
+	"line":        true,
+	"func":        true,
+	"error":       true,
+	"error_chain": true,
+	"stack":       true,
+}
+
+func (m *logMess) MarshalJSON() ([]byte, error) {
+	type mess logMess
+	b, err := json.Marshal((*mess)(m))
+	if err != nil || len(m.fields) == 0 {
+		return b, err
+	}
+
+	buf := bytes.NewBuffer(b[:len(b)-1])
+	for _, f := range m.fields {
+		key := f.Key
+		if reservedKeys[key] {
+			key = "fields." + key
+		}
+		k, _ := json.Marshal(key)
+		buf.WriteByte(',')
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(jsonValue(f.Value))
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func jsonValue(value any) []byte {
+	switch val := value.(type) {
+	case error:
+		value = val.Error()
+	case json.Marshaler:
+	case fmt.Stringer:
+		value = val.String()
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+
+	return b
+}
+
+// errorChain returns messages of err & all errors wrapped by it
+func errorChain(err error) []string {
+	chain := make([]string, 0)
+	for err != nil {
+		msg := err.Error()
+		if len(chain) == 0 || chain[len(chain)-1] != msg {
+			chain = append(chain, msg)
+		}
+
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				chain = append(chain, errorChain(e)...)
+			}
+			break
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	return chain
+}
+
+// stripColors removes color sequences from s
+func stripColors(s string) string {
+	for i := strings.Index(s, LogPutColor); i > -1; i = strings.Index(s, LogPutColor) {
+		end := strings.IndexByte(s[i:], 'm')
+		if end < 0 {
+			break
+		}
+		s = s[:i] + s[i+end+1:]
+	}
+
+	return s
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := make(chanWriter, 10)
+	l := NewLogger(WithOutput(out), WithConsoleEncoder(JSONEncoder{}), WithEncoder(JSONEncoder{}))
+	l.SetWriters(w, FgAll)
+
+	err := errors.Wrap(errors.New("inner"), "outer")
+	l.With("user", 7, "level", "x").ErrorLog(err, "request %s", "failed")
+
+	line := w.next(t)
+	assert.True(t, strings.HasSuffix(line, "}\n"))
+	assert.NotContains(t, line, LogPutColor)
+
+	var mess map[string]any
+	require.NoError(t, json.Unmarshal([]byte(line), &mess))
+	assert.Equal(t, "ERROR", mess["level"])
+	assert.Equal(t, "encoder_test.go", mess["file"])
+	assert.Equal(t, "TestJSONEncoder", mess["func"])
+	assert.Equal(t, float64(7), mess["user"])
+	assert.Equal(t, "x", mess["fields.level"])
+	assert.Equal(t, "outer: inner", mess["error"])
+	assert.Equal(t, []any{"outer: inner", "inner"}, mess["error_chain"])
+	assert.Contains(t, mess["message"], "request failed")
+	assert.NotEmpty(t, mess["stack"])
+	assert.NotEmpty(t, mess["@timestamp"])
+
+	console := out.String()
+	assert.NotContains(t, console, LogPutColor)
+	require.NoError(t, json.Unmarshal([]byte(console), &mess))
+
+	out.Reset()
+	l.ErrorStack(errors.New("stack"))
+	w.next(t)
+	require.NoError(t, json.Unmarshal(out.Bytes(), &mess))
+	assert.Equal(t, "stack", mess["error"])
+	assert.NotEmpty(t, mess["stack"])
+	assert.NotContains(t, mess["message"], prefErrStack)
+}
+
+func TestWriterEncoders(t *testing.T) {
+	text, jsonW := make(chanWriter, 10), make(chanWriter, 10)
+	l := NewLogger(WithOutput(&bytes.Buffer{}))
+	l.AddWriter(text)
+	l.AddWriter(jsonW, UseEncoder(JSONEncoder{}))
+
+	l.StatusLog("status", F("id", 1))
+	assert.Contains(t, text.next(t), "encoder_test.go:")
+	line := jsonW.next(t)
+	assert.True(t, json.Valid([]byte(line)), line)
+	assert.Contains(t, line, `"id":1`)
+
+	l.CustomLog(NOTICE, "CUSTOM", "file.go", 10, "custom message", FgInfo)
+	assert.Contains(t, text.next(t), "[[CUSTOM]]")
+	assert.Contains(t, jsonW.next(t), `"message":"custom message","@timestamp"`)
+}
+
+func TestErrorChain(t *testing.T) {
+	assert.Equal(t, []string{"fake error"}, errorChain(fakeErr{}))
+	assert.Equal(t, []string{"a: fake error", "fake error"}, errorChain(errors.Wrap(fakeErr{}, "a")))
+	assert.Equal(t, "text bold", stripColors(colorSeq(ColorRed)+"text "+colorSeqBold(ColorRed)+"bold"+LogEndColor))
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	w := make(chanWriter, 10)
+	l := NewLogger(WithOutput(&bytes.Buffer{}), WithEncoder(LogfmtEncoder{}))
+	l.AddWriter(w)
+
+	l.WarningLog("disk \"sda\" is full", F("free space", 0), F("path", "/var/log"), F("empty", ""))
+	line := w.next(t)
+	assert.True(t, strings.HasPrefix(line, "ts="), line)
+	assert.Contains(t, line, ` level=warning caller=encoder_test.go:`)
+	assert.Contains(t, line, ` func=logs.TestLogfmtEncoder msg="disk \"sda\" is full" free_space=0 path=/var/log empty=""`+"\n")
+
+	l.ErrorLog(fakeErr{}, F("user", "a=b"))
+	line = w.next(t)
+	assert.Contains(t, line, ` level=error `)
+	assert.Contains(t, line, ` error="fake error" user="a=b"`)
+
+	buf := &bytes.Buffer{}
+	rec := &Record{Level: INFO, Message: "multi\nline", Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	require.NoError(t, LogfmtEncoder{TimeFormat: time.DateTime}.Encode(buf, rec))
+	assert.Equal(t, `ts="2020-01-02 03:04:05" level=info msg="multi\nline"`+"\n", buf.String())
+}
@@ -0,0 +1,322 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrWriteTimeout is reported by parallel MultiWriter for writer which didn't write data in time,
+	// the writer continues writing in background
+	ErrWriteTimeout = errors.New("write timeout, writer continues in background")
+	// ErrWriterBusy is reported by parallel MultiWriter when queue of writer is full, data is dropped for it
+	ErrWriterBusy = errors.New("queue of writer is full, data is dropped")
+
+	errLaneClosed = errors.New("writer is removed from MultiWriter")
+)
+
+const defaultFanOutQueue = 1024
+
+// states of write job
+const (
+	jobPending int32 = iota
+	jobDone
+	// jobAbandoned is job which result nobody waits, its error is reported by next writing
+	jobAbandoned
+)
+
+// fanOut writes data to every writer by own goroutine (lane) & waits them up to timeout
+type fanOut struct {
+	timeout   time.Duration
+	queueSize int
+
+	lock  sync.Mutex
+	lanes map[io.Writer]*writerLane
+}
+
+func newFanOut(timeout time.Duration, queueSize int) *fanOut {
+	if queueSize <= 0 {
+		queueSize = defaultFanOutQueue
+	}
+
+	return &fanOut{timeout: timeout, queueSize: queueSize, lanes: make(map[io.Writer]*writerLane)}
+}
+
+// writeJob is call of writer in its lane
+type writeJob struct {
+	fn    func(io.Writer) (int, error)
+	state atomic.Int32
+	done  chan writeResult
+}
+
+type writeResult struct {
+	n   int
+	err error
+}
+
+// writerLane is goroutine writing to w jobs of its queue
+type writerLane struct {
+	w    io.Writer
+	jobs chan *writeJob
+
+	// lock protects closing of jobs from sending
+	lock   sync.RWMutex
+	closed bool
+	// stopped is closed with jobs, freed signals waiting pushWait about free place in queue
+	stopped chan struct{}
+	freed   chan struct{}
+
+	errLock sync.Mutex
+	// err is error of abandoned job
+	err error
+
+	pending atomic.Int64
+	// stalled is set when writer didn't write in time, lane isn't waited until it writes pending data
+	stalled atomic.Bool
+}
+
+// lane returns lane of w, it is started on first writing
+func (f *fanOut) lane(w io.Writer) *writerLane {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	lane, ok := f.lanes[w]
+	if !ok {
+		lane = &writerLane{
+			w:       w,
+			jobs:    make(chan *writeJob, f.queueSize),
+			stopped: make(chan struct{}),
+			freed:   make(chan struct{}, 1),
+		}
+		f.lanes[w] = lane
+		go lane.run()
+	}
+
+	return lane
+}
+
+// stop stops lanes of writers matching fn, they finish pending jobs before exit
+func (f *fanOut) stop(fn func(io.Writer) bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for w, lane := range f.lanes {
+		if fn(w) {
+			lane.close()
+			delete(f.lanes, w)
+		}
+	}
+}
+
+// waitingJob is job which result write waits
+type waitingJob struct {
+	lane *writerLane
+	job  *writeJob
+}
+
+// write passes copy of p to lanes of writers matching accept without waiting,
+// returned wait waits results up to timeout, it may be called without lock of writers
+func (f *fanOut) write(writers []io.Writer, p []byte, accept func(io.Writer) bool,
+	writeTo func(io.Writer, []byte) (int, error)) (wait func() []WriterErr) {
+	// writers may write p after return
+	p = bytes.Clone(p)
+	fn := func(w io.Writer) (int, error) {
+		return writeTo(w, p)
+	}
+
+	jobs := make([]waitingJob, 0, len(writers))
+	errList := make([]WriterErr, 0)
+	for _, w := range writers {
+		if !accept(w) {
+			continue
+		}
+
+		lane := f.lane(w)
+		if err := lane.takeErr(); err != nil {
+			errList = append(errList, WriterErr{err, w})
+		}
+
+		job := &writeJob{fn: fn, done: make(chan writeResult, 1)}
+		stalled := lane.stalled.Load()
+		if stalled {
+			job.state.Store(jobAbandoned)
+		}
+		if err := lane.push(job); err != nil {
+			errList = append(errList, WriterErr{err, w})
+			continue
+		}
+		if !stalled {
+			jobs = append(jobs, waitingJob{lane, job})
+		}
+	}
+
+	return func() []WriterErr {
+		return f.wait(jobs, len(p), errList)
+	}
+}
+
+// wait waits results of jobs up to timeout & appends their errors to errList
+func (f *fanOut) wait(jobs []waitingJob, size int, errList []WriterErr) []WriterErr {
+	timer := time.NewTimer(f.timeout)
+	defer timer.Stop()
+
+	expired := false
+	for _, item := range jobs {
+		var (
+			res  writeResult
+			done bool
+		)
+		if expired {
+			select {
+			case res = <-item.job.done:
+				done = true
+			default:
+			}
+		} else {
+			select {
+			case res = <-item.job.done:
+				done = true
+			case <-timer.C:
+				expired = true
+			}
+		}
+
+		if !done {
+			if item.job.state.CompareAndSwap(jobPending, jobAbandoned) {
+				item.lane.stalled.Store(true)
+				errList = append(errList, WriterErr{ErrWriteTimeout, item.lane.w})
+				continue
+			}
+			// the job is finished right now
+			res = <-item.job.done
+		}
+
+		errList = appendWriteErrors(errList, item.lane.w, res.n, size, res.err)
+	}
+
+	return errList
+}
+
+// call calls fn in lane of w after its pending jobs, waiting is limited by ctx
+func (f *fanOut) call(ctx context.Context, w io.Writer, fn func(io.Writer) error) error {
+	job := &writeJob{
+		fn: func(w io.Writer) (int, error) {
+			return 0, fn(w)
+		},
+		done: make(chan writeResult, 1),
+	}
+
+	f.lock.Lock()
+	lane := f.lanes[w]
+	f.lock.Unlock()
+
+	// writer without lane hasn't pending data
+	if lane == nil {
+		return fn(w)
+	}
+	if err := lane.pushWait(ctx, job); err == errLaneClosed {
+		return fn(w)
+	} else if err != nil {
+		return err
+	}
+
+	select {
+	case res := <-job.done:
+		return errors.Join(lane.takeErr(), res.err)
+	case <-ctx.Done():
+		if job.state.CompareAndSwap(jobPending, jobAbandoned) {
+			return ctx.Err()
+		}
+		return (<-job.done).err
+	}
+}
+
+func (l *writerLane) run() {
+	for job := range l.jobs {
+		select {
+		case l.freed <- struct{}{}:
+		default:
+		}
+
+		n, err := job.fn(l.w)
+		if job.state.CompareAndSwap(jobPending, jobDone) {
+			job.done <- writeResult{n, err}
+		} else if err != nil {
+			l.errLock.Lock()
+			l.err = err
+			l.errLock.Unlock()
+		}
+
+		if l.pending.Add(-1) == 0 {
+			l.stalled.Store(false)
+		}
+	}
+}
+
+// push adds job to queue without waiting, returns ErrWriterBusy if queue is full
+func (l *writerLane) push(job *writeJob) error {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if l.closed {
+		return errLaneClosed
+	}
+
+	l.pending.Add(1)
+	select {
+	case l.jobs <- job:
+		return nil
+	default:
+		l.pending.Add(-1)
+		return ErrWriterBusy
+	}
+}
+
+// pushWait adds job to queue, it waits free place in queue until ctx is done,
+// waiting doesn't hold lock, so lane may be closed meanwhile
+func (l *writerLane) pushWait(ctx context.Context, job *writeJob) error {
+	for {
+		if err := l.push(job); err != ErrWriterBusy {
+			return err
+		}
+
+		select {
+		case <-l.freed:
+		case <-l.stopped:
+			return errLaneClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// takeErr returns & resets error of abandoned job
+func (l *writerLane) takeErr() error {
+	l.errLock.Lock()
+	defer l.errLock.Unlock()
+
+	err := l.err
+	l.err = nil
+
+	return err
+}
+
+func (l *writerLane) close() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.closed {
+		l.closed = true
+		close(l.jobs)
+		close(l.stopped)
+	}
+}
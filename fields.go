@@ -0,0 +1,167 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Field is a typed key/value pair of log record.
+// Fields passed as arguments of log functions are not part of message,
+// they are rendered as 'key=value' on console & as properties by structured encoders
+type Field struct {
+	Key   string
+	Value any
+}
+
+// F creates Field for arguments of log functions, ex. logs.ErrorLog(err, logs.F("user", id))
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+func (f Field) String() string {
+	b := &strings.Builder{}
+	writeField(b, f)
+
+	return b.String()
+}
+
+// Entry is a set of fields which will be added to every record logged with it
+type Entry struct {
+	logger *Logger
+	fields []Field
+}
+
+// With returns Entry of default logger with fields from args, they are pairs of key & value or Field
+func With(args ...any) *Entry {
+	return Default().With(args...)
+}
+
+// With returns Entry with fields from args, they are pairs of key & value or Field
+func (l *Logger) With(args ...any) *Entry {
+	return &Entry{logger: l, fields: argsToFields(args)}
+}
+
+// With returns new Entry with fields of e & fields from args
+func (e *Entry) With(args ...any) *Entry {
+	fields := make([]Field, 0, len(e.fields)+len(args))
+	fields = append(fields, e.fields...)
+
+	return &Entry{logger: e.logger, fields: append(fields, argsToFields(args)...)}
+}
+
+// Fields returns fields of e
+func (e *Entry) Fields() []Field {
+	return append([]Field(nil), e.fields...)
+}
+
+// args adds fields of e to args of log functions
+func (e *Entry) args(args []any) []any {
+	all := make([]any, 0, len(args)+1)
+
+	return append(append(all, args...), e.fields)
+}
+
+func argsToFields(args []any) []Field {
+	fields := make([]Field, 0, len(args)/2)
+	for i := 0; i < len(args); i++ {
+		switch val := args[i].(type) {
+		case Field:
+			fields = append(fields, val)
+		case []Field:
+			fields = append(fields, val...)
+		default:
+			f := Field{Key: fmt.Sprint(val)}
+			if i+1 < len(args) {
+				i++
+				f.Value = args[i]
+			}
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// splitFields separates fields from other arguments of log functions, args is not modified
+func splitFields(args []any) ([]any, []Field) {
+	var (
+		fields []Field
+		rest   []any
+		found  bool
+	)
+	for i, arg := range args {
+		switch val := arg.(type) {
+		case Field:
+			fields = append(fields, val)
+		case []Field:
+			fields = append(fields, val...)
+		default:
+			if found {
+				rest = append(rest, arg)
+			}
+			continue
+		}
+
+		if !found {
+			found = true
+			rest = append(make([]any, 0, len(args)), args[:i]...)
+		}
+	}
+
+	if !found {
+		return args, nil
+	}
+
+	return rest, fields
+}
+
+// writeFields renders fields as ' key=value' pairs
+func writeFields(w io.Writer, fields []Field) {
+	for _, f := range fields {
+		w.Write(s2b(" "))
+		writeField(w, f)
+	}
+}
+
+func writeField(w io.Writer, f Field) {
+	w.Write(s2b(f.Key))
+	w.Write(s2b("="))
+	w.Write(s2b(quoteValue(fieldValue(f.Value))))
+}
+
+// fieldValue returns text of value of field
+func fieldValue(value any) string {
+	switch val := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return val
+	case error:
+		return val.Error()
+	case fmt.Stringer:
+		return val.String()
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// quoteValue quotes s if it is empty or has spaces, quotes, '=' or not printable symbols
+func quoteValue(s string) string {
+	if s == "" || strings.IndexFunc(s, needsQuote) > -1 {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+func needsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r)
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordWriter chan *Record
+
+func (c recordWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (c recordWriter) WriteRecord(rec *Record) error {
+	c <- rec
+
+	return nil
+}
+
+func TestFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := make(chanWriter, 10)
+	records := make(recordWriter, 10)
+	l := NewLogger(WithOutput(out), WithWriters(w, FgAll))
+	l.AddWriter(records, MinLevel(ERROR))
+
+	l.With("user", 42, F("request", "a b")).ErrorLog(fakeErr{}, "test %s", "fields")
+	line := w.next(t)
+	assert.Contains(t, line, `test fields user=42 request="a b"`)
+	assert.Contains(t, out.String(), `test fields user=42 request="a b"`)
+
+	rec := <-records
+	assert.Equal(t, ERROR, rec.Level)
+	assert.Equal(t, []Field{{"user", 42}, {"request", "a b"}}, rec.Fields)
+	assert.Equal(t, "fields_test.go", rec.File)
+	assert.Equal(t, "logs.TestFields", rec.Func)
+	assert.Contains(t, rec.Message, "test fields")
+	assert.NotContains(t, rec.Message, "user=42")
+
+	l.StatusLog("status", F("id", "x1"), 3)
+	assert.Contains(t, w.next(t), "status,3 id=x1")
+
+	entry := l.With("service", "api")
+	entry.With("empty", "").WarningLog("nested")
+	assert.Contains(t, w.next(t), `nested service=api empty=""`)
+	assert.Equal(t, []Field{{"service", "api"}}, entry.Fields())
+}
+
+func TestSplitFields(t *testing.T) {
+	args := []any{"format %d", F("a", 1), 2, []Field{{"b", nil}}}
+	rest, fields := splitFields(args)
+
+	assert.Equal(t, []any{"format %d", 2}, rest)
+	assert.Equal(t, []Field{{"a", 1}, {"b", nil}}, fields)
+	assert.Len(t, args, 4)
+
+	rest, fields = splitFields([]any{"no fields"})
+	assert.Equal(t, []any{"no fields"}, rest)
+	assert.Nil(t, fields)
+
+	assert.Equal(t, "b=nil", Field{"b", nil}.String())
+	assert.Equal(t, []Field{{"key", nil}}, argsToFields([]any{"key"}))
+}
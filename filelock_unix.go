@@ -0,0 +1,32 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package logs
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// lockFile takes exclusive advisory lock of f, other processes wait for unlock
+func lockFile(f *os.File) (func(), error) {
+	fd := int(f.Fd())
+	for {
+		err := syscall.Flock(fd, syscall.LOCK_EX)
+		if err == nil {
+			break
+		}
+		if err != syscall.EINTR {
+			return nil, errors.Wrap(err, "flock")
+		}
+	}
+
+	return func() {
+		_ = syscall.Flock(fd, syscall.LOCK_UN)
+	}, nil
+}
@@ -0,0 +1,577 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RotateInterval is period of rotation of FileWriter
+type RotateInterval int8
+
+const (
+	// RotateNever disables rotation by time
+	RotateNever RotateInterval = iota
+	// RotateHourly rotates file at the beginning of every hour
+	RotateHourly
+	// RotateDaily rotates file at local midnight
+	RotateDaily
+)
+
+// next returns time of rotation after t
+func (i RotateInterval) next(t time.Time) time.Time {
+	switch i {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Time{}
+	}
+}
+
+// start returns beginning of period of t
+func (i RotateInterval) start(t time.Time) time.Time {
+	switch i {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return t
+	}
+}
+
+// backupTimeFormat is format of timestamp in names of rotated files
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+const gzipExt = ".gz"
+
+// pipeBuf is size of records which O_APPEND writes without interleaving (PIPE_BUF of POSIX),
+// larger records are written under flock in multi-process mode
+const pipeBuf = 512
+
+// FileWriter writes records to file & rotates it by size and/or time,
+// rotated files are named 'name-<timestamp>.ext' & may be compressed with gzip.
+// It is safe for concurrent use
+type FileWriter struct {
+	path     string
+	maxSize  int64
+	interval RotateInterval
+	compress bool
+	maxAge   time.Duration
+	maxCount int
+	signals  []os.Signal
+	shared   bool
+	// onError reports errors of background work: reopening on signals, compressing & removing of rotated files
+	onError func(err error)
+
+	lock     sync.Mutex
+	file     *os.File
+	size     int64
+	rotateAt time.Time
+	// started is beginning of period of file or time of previous rotation by size within the period,
+	// with rotation by time rotated file is named with it
+	started time.Time
+	// mill compresses & removes old rotated files
+	millLock sync.Mutex
+	mill     sync.WaitGroup
+	// stop ends watching of signals
+	stop chan struct{}
+	now  func() time.Time
+}
+
+// FileOption configures FileWriter
+type FileOption func(*FileWriter)
+
+// RotateSize sets max size of file in bytes, 0 disables rotation by size
+func RotateSize(size int64) FileOption {
+	return func(w *FileWriter) {
+		w.maxSize = size
+	}
+}
+
+// RotateEvery sets period of rotation, rotated files are named with beginning of their period
+func RotateEvery(interval RotateInterval) FileOption {
+	return func(w *FileWriter) {
+		w.interval = interval
+	}
+}
+
+// CompressRotated compresses rotated files with gzip
+func CompressRotated() FileOption {
+	return func(w *FileWriter) {
+		w.compress = true
+	}
+}
+
+// MaxAge sets time of keeping rotated files, 0 keeps files forever
+func MaxAge(age time.Duration) FileOption {
+	return func(w *FileWriter) {
+		w.maxAge = age
+	}
+}
+
+// MaxBackups sets count of kept rotated files, 0 keeps all files
+func MaxBackups(count int) FileOption {
+	return func(w *FileWriter) {
+		w.maxCount = count
+	}
+}
+
+// ReopenOnSignal makes writer reopen its path on signals (SIGHUP by default),
+// so external logrotate may move file without copytruncate
+func ReopenOnSignal(signals ...os.Signal) FileOption {
+	return func(w *FileWriter) {
+		if len(signals) == 0 {
+			signals = []os.Signal{syscall.SIGHUP}
+		}
+		w.signals = signals
+	}
+}
+
+// MultiProcess makes writer safe for processes which append to the same file:
+// every record is written by single write, records longer than PIPE_BUF & rotation are done under flock,
+// size of file is read before every write & file is reopened if other process has rotated it
+func MultiProcess() FileOption {
+	return func(w *FileWriter) {
+		w.shared = true
+	}
+}
+
+// OnFileError sets handler of errors of reopening on signals, compressing & removing of rotated files,
+// they are printed to os.Stderr by default, so they don't return to the writer through a logger
+func OnFileError(fn func(err error)) FileOption {
+	return func(w *FileWriter) {
+		w.onError = fn
+	}
+}
+
+// NewFileWriter opens (or creates) file at path for appending records,
+// ex. logs.SetWriters(logs.NewFileWriter("app.log", logs.RotateSize(100<<20), logs.MaxBackups(5)), logs.FgAll)
+func NewFileWriter(path string, opts ...FileOption) (*FileWriter, error) {
+	w := &FileWriter{path: path, now: time.Now, onError: printFileError}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	if len(w.signals) > 0 {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, w.signals...)
+		w.stop = make(chan struct{})
+		go w.watchSignals(ch, w.stop)
+	}
+
+	return w, nil
+}
+
+// watchSignals reopens file on signals until stop is closed
+func (w *FileWriter) watchSignals(ch chan os.Signal, stop chan struct{}) {
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ch:
+			if err := w.Reopen(); err != nil {
+				w.onError(errors.Wrapf(err, "reopen %s", w.path))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Write writes p as line of file (adds new line if p doesn't end with it),
+// file is rotated before writing if p exceeds max size or period of file is over
+func (w *FileWriter) Write(p []byte) (int, error) {
+	size := len(p)
+	if size > 0 && p[size-1] != '\n' {
+		p = append(p[:size:size], '\n')
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.shared {
+		unlock, err := w.lockShared(len(p))
+		if err != nil {
+			return 0, err
+		}
+		defer unlock()
+	} else if w.rotationDue(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if n > size {
+		n = size
+	}
+
+	return n, err
+}
+
+// rotationDue reports whether file must be rotated before writing of record of size
+func (w *FileWriter) rotationDue(size int) bool {
+	return w.interval != RotateNever && !w.now().Before(w.rotateAt) ||
+		w.maxSize > 0 && w.size > 0 && w.size+int64(size) > w.maxSize
+}
+
+// lockShared prepares file for record of size in multi-process mode: reopens file rotated by other process,
+// rotates file under flock if it's needed & locks file for record longer than PIPE_BUF
+func (w *FileWriter) lockShared(size int) (func(), error) {
+	for {
+		same, err := w.statShared()
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			if err := w.close(); err != nil {
+				return nil, err
+			}
+			if err := w.open(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if size <= pipeBuf && !w.rotationDue(size) {
+			return func() {}, nil
+		}
+
+		unlock, err := lockFile(w.file)
+		if err != nil {
+			return nil, errors.Wrap(err, "lock log file")
+		}
+
+		// other process might rotate file while we waited for lock
+		same, err = w.statShared()
+		switch {
+		case err != nil:
+			unlock()
+			return nil, err
+		case !same:
+			unlock()
+		case w.rotationDue(size):
+			// closing of file by rotation releases its lock, new file is locked on next loop
+			if err := w.rotate(); err != nil {
+				return nil, err
+			}
+		case size <= pipeBuf:
+			unlock()
+			return func() {}, nil
+		default:
+			return unlock, nil
+		}
+	}
+}
+
+// statShared reads size of file written by other processes & reports whether path still names the file
+func (w *FileWriter) statShared() (bool, error) {
+	info, err := w.file.Stat()
+	if err != nil {
+		return false, errors.Wrap(err, "stat log file")
+	}
+	w.size = info.Size()
+
+	pathInfo, err := os.Stat(w.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "stat log file")
+	}
+
+	return os.SameFile(info, pathInfo), nil
+}
+
+// Rotate closes current file, renames it with timestamp & opens new file
+func (w *FileWriter) Rotate() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.rotate()
+}
+
+// Reopen closes file & opens its path again, records written concurrently go to the old or to the new file entirely
+func (w *FileWriter) Reopen() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	return w.open()
+}
+
+// Sync commits written data to disk
+func (w *FileWriter) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	return w.file.Sync()
+}
+
+// Close closes file, stops watching signals & waits for compression of rotated files, next Write opens file again
+func (w *FileWriter) Close() error {
+	w.lock.Lock()
+	err := w.close()
+	stop := w.stop
+	w.stop = nil
+	w.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+	}
+
+	w.mill.Wait()
+
+	return err
+}
+
+// Path returns path of current file
+func (w *FileWriter) Path() string {
+	return w.path
+}
+
+func (w *FileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return errors.Wrap(err, "create dir of log file")
+	}
+
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "open log file")
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "stat log file")
+	}
+
+	w.file = f
+	w.size = info.Size()
+	// file from previous period is rotated on first write
+	opened := w.now()
+	if w.size > 0 && info.ModTime().Before(opened) {
+		opened = info.ModTime()
+	}
+	w.rotateAt = w.interval.next(opened)
+	w.started = w.interval.start(opened)
+
+	return nil
+}
+
+func (w *FileWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return errors.Wrap(err, "close log file")
+}
+
+// rotate renames file with timestamp: time of beginning of its records with rotation by time, otherwise time of rotation
+func (w *FileWriter) rotate() error {
+	now := w.now()
+	stamp := now
+	if w.interval != RotateNever {
+		stamp = w.started
+	}
+	periodOver := w.interval != RotateNever && !now.Before(w.rotateAt)
+
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(w.path); err == nil {
+		if err := os.Rename(w.path, w.backupName(stamp)); err != nil {
+			return errors.Wrap(err, "rename log file")
+		}
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+	if !periodOver {
+		w.started = now
+	}
+
+	if w.compress || w.maxAge > 0 || w.maxCount > 0 {
+		w.mill.Add(1)
+		go w.millBackups()
+	}
+
+	return nil
+}
+
+// nameParts returns file name without extension & extension of path
+func (w *FileWriter) nameParts() (string, string) {
+	name := filepath.Base(w.path)
+	ext := filepath.Ext(name)
+
+	return strings.TrimSuffix(name, ext), ext
+}
+
+// backupName returns unused name of rotated file with timestamp t
+func (w *FileWriter) backupName(t time.Time) string {
+	prefix, ext := w.nameParts()
+	name := filepath.Join(filepath.Dir(w.path), prefix+"-"+t.Format(backupTimeFormat))
+	for i := 1; ; i++ {
+		path := name + ext
+		if i > 1 {
+			path = name + "." + strconv.Itoa(i) + ext
+		}
+
+		if !fileExists(path) && !fileExists(path+gzipExt) {
+			return path
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// backup is rotated file
+type backup struct {
+	path string
+	time time.Time
+}
+
+// backups returns rotated files sorted from newest to oldest
+func (w *FileWriter) backups() ([]backup, error) {
+	entries, err := os.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return nil, errors.Wrap(err, "read dir of log file")
+	}
+
+	prefix, ext := w.nameParts()
+	list := make([]backup, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix+"-") {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, gzipExt), ext)
+		stamp = strings.TrimPrefix(stamp, prefix+"-")
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+
+		t, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+
+		list = append(list, backup{filepath.Join(filepath.Dir(w.path), name), t})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].time.After(list[j].time)
+	})
+
+	return list, nil
+}
+
+// millBackups compresses rotated files & removes files exceeding max age or max count
+func (w *FileWriter) millBackups() {
+	defer w.mill.Done()
+
+	w.millLock.Lock()
+	defer w.millLock.Unlock()
+
+	list, err := w.backups()
+	if err != nil {
+		w.onError(errors.Wrap(err, w.path))
+		return
+	}
+
+	for i, b := range list {
+		if w.maxCount > 0 && i >= w.maxCount || w.maxAge > 0 && w.now().Sub(b.time) > w.maxAge {
+			if err := os.Remove(b.path); err != nil {
+				w.onError(errors.Wrap(err, "remove rotated log file"))
+			}
+			continue
+		}
+
+		if w.compress && !strings.HasSuffix(b.path, gzipExt) {
+			if err := compressFile(b.path); err != nil {
+				w.onError(errors.Wrap(err, b.path))
+			}
+		}
+	}
+}
+
+// printFileError is default handler of errors of FileWriter
+func printFileError(err error) {
+	fmt.Fprintf(os.Stderr, "%s FileWriter: %v\n", time.Now().Format(time.DateTime), err)
+}
+
+// compressFile replaces file at path with its gzip copy
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "open rotated log file")
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+gzipExt, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "create compressed log file")
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + gzipExt)
+		return errors.Wrap(err, "compress rotated log file")
+	}
+
+	return errors.Wrap(os.Remove(path), "remove rotated log file")
+}
@@ -0,0 +1,314 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestFileWriterSize(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2024, 5, 6, 10, 0, 0, 0, time.Local)
+	w, err := NewFileWriter(filepath.Join(dir, "app.log"), RotateSize(10), MaxBackups(2))
+	require.NoError(t, err)
+	w.now = func() time.Time { return clock }
+
+	for _, line := range []string{"first", "second\n", "third", "fourth\n"} {
+		clock = clock.Add(time.Second)
+		n, err := w.Write([]byte(line))
+		require.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{"app-2024-05-06T10-00-03.000.log", "app-2024-05-06T10-00-04.000.log", "app.log"}, dirFiles(t, dir))
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "fourth\n", string(b))
+	b, err = os.ReadFile(filepath.Join(dir, "app-2024-05-06T10-00-04.000.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(b))
+}
+
+func TestFileWriterOnError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP isn't supported")
+	}
+
+	dir := filepath.Join(t.TempDir(), "logs")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	errs := make(chan error, 10)
+	w, err := NewFileWriter(filepath.Join(dir, "app.log"), ReopenOnSignal(), OnFileError(func(err error) { errs <- err }))
+	require.NoError(t, err)
+	defer w.Close()
+
+	// file can't be reopened when its directory is replaced with file
+	require.NoError(t, os.RemoveAll(dir))
+	require.NoError(t, os.WriteFile(dir, nil, 0o644))
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, p.Signal(syscall.SIGHUP))
+
+	select {
+	case err := <-errs:
+		assert.ErrorContains(t, err, "reopen "+filepath.Join(dir, "app.log"))
+		assert.ErrorIs(t, err, syscall.ENOTDIR)
+	case <-time.After(time.Second):
+		t.Fatal("error of reopening wasn't reported")
+	}
+
+	// mill reports errors through the same handler
+	w.mill.Add(1)
+	w.millBackups()
+	assert.ErrorContains(t, <-errs, "read dir of log file")
+}
+
+func TestFileWriterInterval(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2024, 5, 6, 23, 59, 0, 0, time.Local)
+	w := &FileWriter{path: filepath.Join(dir, "app.log"), interval: RotateDaily, compress: true, maxAge: 48 * time.Hour,
+		now: func() time.Time { return clock }}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app-2024-05-01T00-00-00.000.log.gz"), nil, 0o644))
+
+	_, err := w.Write([]byte("yesterday\n"))
+	require.NoError(t, err)
+
+	clock = clock.Add(2 * time.Minute)
+	_, err = w.Write([]byte("today\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{"app-2024-05-06T00-00-00.000.log.gz", "app.log"}, dirFiles(t, dir))
+
+	f, err := os.Open(filepath.Join(dir, "app-2024-05-06T00-00-00.000.log.gz"))
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	b, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "yesterday\n", string(b))
+}
+
+func TestFileWriterIntervalSize(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2024, 5, 6, 10, 5, 0, 0, time.Local)
+	w := &FileWriter{path: filepath.Join(dir, "app.log"), interval: RotateHourly, maxSize: 10,
+		now: func() time.Time { return clock }}
+
+	// backups are named with beginning of their records
+	for _, minutes := range []time.Duration{0, 15, 60} {
+		clock = clock.Add(minutes * time.Minute)
+		_, err := w.Write([]byte("record"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{"app-2024-05-06T10-00-00.000.log", "app-2024-05-06T10-20-00.000.log", "app.log"},
+		dirFiles(t, dir))
+}
+
+func TestFileWriterConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewFileWriter(filepath.Join(dir, "app.log"), RotateSize(1000))
+	require.NoError(t, err)
+
+	l := NewLogger(WithOutput(io.Discard), WithQueue(QueueOptions{Workers: 4}))
+	l.AddWriter(w)
+	l.AddWriter(io.Discard)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 50 {
+				l.StatusLog("concurrent record")
+			}
+		})
+	}
+	wg.Wait()
+	require.NoError(t, l.Shutdown(t.Context()))
+
+	lines := 0
+	for _, name := range dirFiles(t, dir) {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(b), 1000)
+		for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			assert.Contains(t, line, "concurrent record")
+			lines++
+		}
+	}
+	assert.Equal(t, 200, lines)
+}
+
+func TestFileWriterReopen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP isn't supported")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := NewFileWriter(path, ReopenOnSignal())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Go(func() {
+			for j := range 100 {
+				_, err := w.Write([]byte(strings.Repeat("x", 100) + " " + strconv.Itoa(i*100+j)))
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	// logrotate moves file & sends SIGHUP
+	require.NoError(t, os.Rename(path, path+".1"))
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, p.Signal(syscall.SIGHUP))
+	wg.Wait()
+
+	assert.Eventually(t, func() bool {
+		return fileExists(path)
+	}, time.Second, 10*time.Millisecond)
+	_, err = w.Write([]byte("after reopen"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	seen := make(map[string]bool)
+	for _, name := range []string{path + ".1", path} {
+		b, err := os.ReadFile(name)
+		require.NoError(t, err)
+		// moved file is empty if signal is handled before writing
+		if len(b) == 0 {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			if line == "after reopen" {
+				assert.Equal(t, path, name)
+				continue
+			}
+			require.True(t, strings.HasPrefix(line, strings.Repeat("x", 100)+" "), line)
+			seen[line[101:]] = true
+		}
+	}
+	assert.Len(t, seen, 400)
+
+	require.NoError(t, w.Reopen())
+	require.NoError(t, w.Close())
+}
+
+func TestFileWriterMultiProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	var wg sync.WaitGroup
+	// every writer has own descriptor of file as separate process
+	for i := range 4 {
+		w, err := NewFileWriter(path, MultiProcess())
+		require.NoError(t, err)
+		wg.Go(func() {
+			defer w.Close()
+			for j := range 50 {
+				size := 100
+				if j%2 == 0 {
+					size = 3 * pipeBuf
+				}
+				_, err := w.Write([]byte(strings.Repeat(strconv.Itoa(i), size)))
+				assert.NoError(t, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	assert.Len(t, lines, 200)
+	for _, line := range lines {
+		require.Equal(t, strings.Repeat(line[:1], len(line)), line)
+	}
+
+	w, err := NewFileWriter(path, MultiProcess())
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(b)), w.size)
+	require.NoError(t, w.Close())
+}
+
+func TestFileWriterMultiProcessRotation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("opened file can't be renamed on windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	const maxSize = 1000
+	writers := make([]*FileWriter, 2)
+	for i := range writers {
+		w, err := NewFileWriter(path, MultiProcess(), RotateSize(maxSize))
+		require.NoError(t, err)
+		writers[i] = w
+	}
+
+	for i := range 40 {
+		size := 99
+		if i%4 == 0 {
+			size = pipeBuf + 100
+		}
+		line := strconv.Itoa(i) + " " + strings.Repeat("x", size)
+		_, err := writers[i%2].Write([]byte(line))
+		require.NoError(t, err)
+	}
+	for _, w := range writers {
+		require.NoError(t, w.Close())
+	}
+
+	// every file has successive records of both writers, none of them writes to rotated file
+	count := 0
+	for _, name := range dirFiles(t, dir) {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(b), maxSize, name)
+
+		prev := -1
+		for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			id, _, _ := strings.Cut(line, " ")
+			i, err := strconv.Atoi(id)
+			require.NoError(t, err)
+			if prev >= 0 {
+				assert.Equal(t, prev+1, i, name)
+			}
+			prev = i
+			count++
+		}
+	}
+	assert.Equal(t, 40, count)
+}
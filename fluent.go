@@ -0,0 +1,323 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FluentWriter sends records to Fluentd or Fluent Bit with Forward protocol over tcp or unix socket.
+// Records are sent one by one as [tag, time, record] or by PackedForward batches (see FluentBatch),
+// tag is prefix & lower-case level of record, ex. 'logs.error'
+type FluentWriter struct {
+	network string
+	addr    string
+	prefix  string
+	ack     bool
+	timeout time.Duration
+	batch   *batcher
+
+	lock   sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// FluentOption configures FluentWriter
+type FluentOption func(*FluentWriter)
+
+// FluentTagPrefix sets prefix of tags of records, 'logs' by default
+func FluentTagPrefix(prefix string) FluentOption {
+	return func(w *FluentWriter) {
+		w.prefix = prefix
+	}
+}
+
+// FluentAck requires acknowledgment of every message by server
+func FluentAck() FluentOption {
+	return func(w *FluentWriter) {
+		w.ack = true
+	}
+}
+
+// FluentTimeout sets timeout of connection, writing & waiting of ack, 5 seconds by default
+func FluentTimeout(timeout time.Duration) FluentOption {
+	return func(w *FluentWriter) {
+		w.timeout = timeout
+	}
+}
+
+// FluentBatch sends records by PackedForward messages (one per tag) according to opts,
+// call Flush or Logger.Shutdown to send the rest of records
+func FluentBatch(opts BatchOptions) FluentOption {
+	return func(w *FluentWriter) {
+		w.batch = newBatcher(opts, w.send)
+	}
+}
+
+// NewFluentWriter creates writer to forward input at addr, network is tcp or unix,
+// ex. logs.AddWriter(logs.NewFluentWriter("tcp", "localhost:24224", logs.FluentAck()))
+func NewFluentWriter(network, addr string, opts ...FluentOption) (*FluentWriter, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, errors.Errorf("unsupported network of fluentd '%s'", network)
+	}
+
+	w := &FluentWriter{
+		network: network,
+		addr:    addr,
+		prefix:  "logs",
+		timeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w, nil
+}
+
+// Write sends p as message of INFO record
+func (w *FluentWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord sends rec or adds it to batch
+func (w *FluentWriter) WriteRecord(rec *Record) error {
+	if w.batch != nil {
+		return w.batch.add(rec, w.entry(rec))
+	}
+
+	chunk := w.chunkID()
+	size := 3
+	if chunk > "" {
+		size++
+	}
+
+	msg := appendMsgpackArray(make([]byte, 0, 256), size)
+	msg = appendMsgpackString(msg, w.tag(rec))
+	msg = appendMsgpackEventTime(msg, recordTime(rec))
+	msg = w.appendRecord(msg, rec)
+	if chunk > "" {
+		msg = appendMsgpackMap(msg, 1)
+		msg = appendMsgpackString(msg, "chunk")
+		msg = appendMsgpackString(msg, chunk)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.sendMessage(msg, chunk)
+}
+
+// Flush sends collected records
+func (w *FluentWriter) Flush(ctx context.Context) error {
+	if w.batch == nil {
+		return nil
+	}
+
+	return w.batch.flush(ctx)
+}
+
+// Dropped returns count of records of batches which weren't sent after retries
+func (w *FluentWriter) Dropped() uint64 {
+	if w.batch == nil {
+		return 0
+	}
+
+	return w.batch.dropped.Load()
+}
+
+// Close sends collected records & closes connection
+func (w *FluentWriter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalTimeout)
+	defer cancel()
+
+	err := w.Flush(ctx)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.conn != nil {
+		if errClose := w.conn.Close(); err == nil {
+			err = errors.Wrap(errClose, "close fluentd connection")
+		}
+		w.conn, w.reader = nil, nil
+	}
+
+	return err
+}
+
+// tag returns tag of rec
+func (w *FluentWriter) tag(rec *Record) string {
+	level := strings.ToLower(rec.Level.String())
+	if w.prefix == "" {
+		return level
+	}
+
+	return w.prefix + "." + level
+}
+
+// chunkID returns random id of message if ack is required
+func (w *FluentWriter) chunkID() string {
+	if !w.ack {
+		return ""
+	}
+
+	b := binary.BigEndian.AppendUint64(nil, rand.Uint64())
+
+	return base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint64(b, rand.Uint64()))
+}
+
+// entry returns [time, record] of PackedForward stream
+func (w *FluentWriter) entry(rec *Record) []byte {
+	b := appendMsgpackArray(make([]byte, 0, 256), 2)
+	b = appendMsgpackEventTime(b, recordTime(rec))
+
+	return w.appendRecord(b, rec)
+}
+
+// appendRecord appends map with message, level, caller, error & fields of rec
+func (w *FluentWriter) appendRecord(b []byte, rec *Record) []byte {
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+
+	fields := make([]Field, 0, len(rec.Fields)+6)
+	fields = append(fields,
+		F("message", strings.TrimRight(stripColors(msg), "\n")),
+		F("level", rec.Level.String()))
+	if rec.File > "" {
+		fields = append(fields, F("file", rec.File), F("line", rec.Line))
+	}
+	if rec.Func > "" {
+		fields = append(fields, F("func", rec.Func))
+	}
+	if rec.Err != nil {
+		fields = append(fields, F("error", rec.Err.Error()))
+	}
+	fields = append(fields, rec.Fields...)
+
+	b = appendMsgpackMap(b, len(fields))
+	for _, f := range fields {
+		b = appendMsgpackString(b, f.Key)
+		b = appendMsgpackValue(b, f.Value)
+	}
+
+	return b
+}
+
+// send sends items as PackedForward message per tag
+func (w *FluentWriter) send(ctx context.Context, items []batchItem) error {
+	tags := make([]string, 0)
+	streams := make(map[string][]byte)
+	counts := make(map[string]int)
+	for _, item := range items {
+		tag := w.tag(item.rec)
+		if _, ok := streams[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		streams[tag] = append(streams[tag], item.data...)
+		counts[tag]++
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, tag := range tags {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "fluentd forward")
+		}
+
+		chunk := w.chunkID()
+		msg := appendMsgpackArray(nil, 3)
+		msg = appendMsgpackString(msg, tag)
+		msg = appendMsgpackBin(msg, streams[tag])
+		if chunk > "" {
+			msg = appendMsgpackMap(msg, 2)
+			msg = appendMsgpackString(msg, "chunk")
+			msg = appendMsgpackString(msg, chunk)
+		} else {
+			msg = appendMsgpackMap(msg, 1)
+		}
+		msg = appendMsgpackString(msg, "size")
+		msg = appendMsgpackUint(msg, uint64(counts[tag]))
+
+		if err := w.sendMessage(msg, chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendMessage writes msg & waits ack of chunk if it isn't empty, connection is restored once if it was broken
+func (w *FluentWriter) sendMessage(msg []byte, chunk string) error {
+	var err error
+	for range 2 {
+		if w.conn == nil {
+			conn, errDial := net.DialTimeout(w.network, w.addr, w.timeout)
+			if errDial != nil {
+				return errors.Wrap(errDial, "connect to fluentd")
+			}
+			w.conn, w.reader = conn, bufio.NewReader(conn)
+		}
+
+		if w.timeout > 0 {
+			_ = w.conn.SetDeadline(time.Now().Add(w.timeout))
+		}
+		if _, err = w.conn.Write(msg); err == nil {
+			break
+		}
+
+		_ = w.conn.Close()
+		w.conn, w.reader = nil, nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "write to fluentd")
+	}
+
+	if chunk == "" {
+		return nil
+	}
+
+	resp, err := readMsgpackStringMap(w.reader)
+	if err == nil && resp["ack"] != chunk {
+		err = errors.Errorf("ack '%s' doesn't match chunk '%s'", resp["ack"], chunk)
+	}
+	if err != nil {
+		// the stream is out of sync after failed ack
+		_ = w.conn.Close()
+		w.conn, w.reader = nil, nil
+
+		return errors.Wrap(err, "fluentd ack")
+	}
+
+	return nil
+}
+
+// recordTime returns time of rec or current time for records without it
+func recordTime(rec *Record) time.Time {
+	if rec.Time.IsZero() {
+		return time.Now()
+	}
+
+	return rec.Time
+}
@@ -0,0 +1,219 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeMsgpack decodes value of types produced by writer, EventTime is decoded as time.Time
+func decodeMsgpack(r *bufio.Reader) (any, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	next := func(n int) []byte {
+		b := make([]byte, n)
+		if _, errRead := io.ReadFull(r, b); errRead != nil {
+			err = errRead
+		}
+		return b
+	}
+	size := func(n int) int {
+		b := append(make([]byte, 4-n), next(n)...)
+		return int(binary.BigEndian.Uint32(b))
+	}
+	items := func(n int, isMap bool) any {
+		if isMap {
+			m := make(map[string]any, n)
+			for range n {
+				key, _ := decodeMsgpack(r)
+				m[key.(string)], err = decodeMsgpack(r)
+			}
+			return m
+		}
+		a := make([]any, n)
+		for i := range a {
+			a[i], err = decodeMsgpack(r)
+		}
+		return a
+	}
+
+	var v any
+	switch {
+	case c < 0x80:
+		v = int64(c)
+	case c >= 0xe0:
+		v = int64(int8(c))
+	case c&0xf0 == 0x80:
+		v = items(int(c&0x0f), true)
+	case c&0xf0 == 0x90:
+		v = items(int(c&0x0f), false)
+	case c&0xe0 == 0xa0:
+		v = string(next(int(c & 0x1f)))
+	case c == 0xc0:
+		v = nil
+	case c == 0xc2, c == 0xc3:
+		v = c == 0xc3
+	case c == 0xc4:
+		v = next(size(1))
+	case c == 0xc5:
+		v = next(size(2))
+	case c == 0xcb:
+		v = math.Float64frombits(binary.BigEndian.Uint64(next(8)))
+	case c == 0xcc, c == 0xcd, c == 0xce:
+		v = int64(size(1 << (c - 0xcc)))
+	case c == 0xd0:
+		v = int64(int8(next(1)[0]))
+	case c == 0xd1:
+		v = int64(int16(binary.BigEndian.Uint16(next(2))))
+	case c == 0xd2:
+		v = int64(int32(binary.BigEndian.Uint32(next(4))))
+	case c == 0xd7:
+		b := next(9)
+		v = time.Unix(int64(binary.BigEndian.Uint32(b[1:])), int64(binary.BigEndian.Uint32(b[5:])))
+	case c == 0xd9:
+		v = string(next(size(1)))
+	case c == 0xda:
+		v = string(next(size(2)))
+	case c == 0xdc:
+		v = items(size(2), false)
+	case c == 0xde:
+		v = items(size(2), true)
+	default:
+		return nil, errors.Errorf("unexpected msgpack type 0x%x", c)
+	}
+
+	return v, err
+}
+
+func TestMsgpackValues(t *testing.T) {
+	values := []any{nil, true, false, 1, -5, -100, 300, -40000, 70000, uint8(200), 1.5, "short",
+		string(bytes.Repeat([]byte("a"), 300)), errors.New("failed")}
+	b := appendMsgpackArray(nil, len(values))
+	for _, v := range values {
+		b = appendMsgpackValue(b, v)
+	}
+
+	got, err := decodeMsgpack(bufio.NewReader(bytes.NewReader(b)))
+	require.NoError(t, err)
+	assert.Equal(t, []any{nil, true, false, int64(1), int64(-5), int64(-100), int64(300), int64(-40000),
+		int64(70000), int64(200), 1.5, "short", string(bytes.Repeat([]byte("a"), 300)), "failed"}, got)
+}
+
+// fluentServer accepts one connection & passes received messages, acks are sent for messages with chunk
+func fluentServer(t *testing.T) (string, <-chan []any) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan []any, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := decodeMsgpack(r)
+			if err != nil {
+				return
+			}
+			items := msg.([]any)
+			if option, ok := items[len(items)-1].(map[string]any); ok && option["chunk"] != nil {
+				ack := appendMsgpackMap(nil, 1)
+				ack = appendMsgpackString(ack, "ack")
+				ack = appendMsgpackString(ack, option["chunk"].(string))
+				_, _ = conn.Write(ack)
+			}
+			messages <- items
+		}
+	}()
+
+	return ln.Addr().String(), messages
+}
+
+func TestFluentMessage(t *testing.T) {
+	addr, messages := fluentServer(t)
+	w, err := NewFluentWriter("tcp", addr, FluentAck())
+	require.NoError(t, err)
+	defer w.Close()
+
+	now := time.Unix(1700000000, 5000)
+	require.NoError(t, w.WriteRecord(&Record{
+		Time:    now,
+		Level:   WARNING,
+		Message: "disk is full",
+		File:    "main.go",
+		Line:    7,
+		Fields:  []Field{F("free", 0.5)},
+	}))
+
+	msg := <-messages
+	require.Len(t, msg, 4)
+	assert.Equal(t, "logs.warning", msg[0])
+	assert.True(t, now.Equal(msg[1].(time.Time)))
+	assert.Equal(t, map[string]any{"message": "disk is full", "level": "WARNING", "file": "main.go",
+		"line": int64(7), "free": 0.5}, msg[2])
+	assert.NotEmpty(t, msg[3].(map[string]any)["chunk"])
+
+	_, err = NewFluentWriter("udp", addr)
+	assert.Error(t, err)
+}
+
+func TestFluentPackedForward(t *testing.T) {
+	addr, messages := fluentServer(t)
+	w, err := NewFluentWriter("tcp", addr, FluentTagPrefix("app"), FluentAck(), FluentBatch(BatchOptions{Interval: time.Hour}))
+	require.NoError(t, err)
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	l.StatusLog("first", F("user", 1))
+	l.StatusLog("second")
+	l.ErrorLog(errors.New("failed"), "third")
+	require.NoError(t, l.Shutdown(t.Context()))
+
+	entries := make(map[string][]any)
+	for range 2 {
+		msg := <-messages
+		require.Len(t, msg, 3)
+		tag := msg[0].(string)
+
+		r := bufio.NewReader(bytes.NewReader(msg[1].([]byte)))
+		for {
+			entry, err := decodeMsgpack(r)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			entries[tag] = append(entries[tag], entry.([]any)[1])
+		}
+		option := msg[2].(map[string]any)
+		assert.Equal(t, int64(len(entries[tag])), option["size"])
+		assert.NotEmpty(t, option["chunk"])
+	}
+
+	require.Len(t, entries["app.info"], 2)
+	assert.Equal(t, "first", entries["app.info"][0].(map[string]any)["message"])
+	assert.Equal(t, int64(1), entries["app.info"][0].(map[string]any)["user"])
+	assert.Equal(t, "second", entries["app.info"][1].(map[string]any)["message"])
+	require.Len(t, entries["app.error"], 1)
+	assert.Equal(t, "failed", entries["app.error"][0].(map[string]any)["error"])
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Flusher is implemented by writers which buffer records, Flush writes buffered records to destination
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// fatalTimeout limits time of delivery of records before exit of Fatal
+const fatalTimeout = 5 * time.Second
+
+// sentryFlushTimeout is time of waiting for sent events of Sentry if ctx has no deadline
+const sentryFlushTimeout = 2 * time.Second
+
+// flushWriter writes buffered data of w: Flush(ctx) of Flusher, Flush() of bufio.Writer or Sync() of os.File
+func flushWriter(ctx context.Context, w io.Writer) error {
+	switch w := w.(type) {
+	case Flusher:
+		return w.Flush(ctx)
+	case interface{ Flush() error }:
+		return w.Flush()
+	case interface{ Sync() error }:
+		return w.Sync()
+	default:
+		return nil
+	}
+}
+
+// closeWriter closes w if it is io.Closer, standard outputs are only flushed
+func closeWriter(ctx context.Context, w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return flushWriter(ctx, w)
+	}
+
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return flushWriter(ctx, w)
+}
+
+// Flush waits until default logger delivers queued records to writers & Sentry, flushes writers
+func Flush(ctx context.Context) error {
+	return Default().Flush(ctx)
+}
+
+// Shutdown flushes default logger & closes its writers
+func Shutdown(ctx context.Context) error {
+	return Default().Shutdown(ctx)
+}
+
+// Flush waits until queued records are delivered to writers & Sentry,
+// then flushes writers which buffer data (Flusher, Sync() of os.File)
+func (l *Logger) Flush(ctx context.Context) error {
+	err := l.flush(ctx, flushWriter)
+
+	return l.flushSentry(ctx, err)
+}
+
+// Shutdown stops queue of records, flushes queued records, closes & removes all writers of the logger,
+// after it records are output only to console
+func (l *Logger) Shutdown(ctx context.Context) error {
+	l.lock.RLock()
+	queue := l.queue
+	l.lock.RUnlock()
+
+	// records logged during closing of writers don't reach them
+	queue.close()
+	err := l.flush(ctx, closeWriter)
+	l.writers.removeFunc(func(io.Writer) bool { return true })
+
+	return l.flushSentry(ctx, err)
+}
+
+func (l *Logger) flush(ctx context.Context, fn func(context.Context, io.Writer) error) error {
+	l.lock.RLock()
+	queue := l.queue
+	l.lock.RUnlock()
+
+	if err := queue.flush(ctx); err != nil {
+		return errors.Wrap(err, "flush queue of records")
+	}
+
+	return l.writers.each(ctx, func(w io.Writer) error {
+		return fn(ctx, w)
+	})
+}
+
+// flushSentry waits for sending events to Sentry, returns err or error of sending
+func (l *Logger) flushSentry(ctx context.Context, err error) error {
+	hub := l.sentryHub()
+	if hub == nil {
+		return err
+	}
+
+	timeout := sentryFlushTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	if !hub.Flush(timeout) && err == nil {
+		err = errors.New("sentry didn't send events in time")
+	}
+
+	return err
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowWriter buffers records slowly & counts flushes & closing
+type slowWriter struct {
+	lock    sync.Mutex
+	buf     bytes.Buffer
+	lines   int
+	flushed int
+	closed  bool
+}
+
+func (w *slowWriter) Write(b []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.lines++
+	return w.buf.Write(b)
+}
+
+func (w *slowWriter) Flush(context.Context) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.flushed++
+	return nil
+}
+
+func (w *slowWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.closed = true
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	w := &slowWriter{}
+	l := NewLogger(WithOutput(&bytes.Buffer{}))
+	l.AddWriter(w)
+
+	for range 50 {
+		l.StatusLog("record")
+	}
+	require.NoError(t, l.Flush(context.Background()))
+
+	w.lock.Lock()
+	assert.Equal(t, 50, w.lines)
+	assert.Equal(t, 1, w.flushed)
+	assert.False(t, w.closed)
+	w.lock.Unlock()
+
+	require.NoError(t, l.Flush(context.Background()))
+}
+
+func TestFlushPriorityLane(t *testing.T) {
+	gate := gateWriter{started: make(chanWriter, 10), gate: make(chan struct{})}
+	w := &slowWriter{}
+	l := NewLogger(WithOutput(&bytes.Buffer{}))
+	l.AddWriter(gate)
+	l.AddWriter(w)
+
+	l.StatusLog("first")
+	assert.Contains(t, chanWriter(gate.started).next(t), "first")
+	l.StatusLog("second")
+	l.StatusLog("third")
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- l.Flush(context.Background())
+	}()
+	// records of priority lane are logged during flush & delivered before waited ones
+	time.Sleep(20 * time.Millisecond)
+	l.Log(ERROR, "error 1")
+	l.Log(ERROR, "error 2")
+	close(gate.gate)
+
+	select {
+	case err := <-flushed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("flush isn't finished")
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	assert.Equal(t, 5, w.lines)
+	assert.Contains(t, w.buf.String(), "third")
+}
+
+func TestFlushTimeout(t *testing.T) {
+	w := gateWriter{started: make(chanWriter, 10), gate: make(chan struct{})}
+	l := NewLogger(WithOutput(&bytes.Buffer{}))
+	l.AddWriter(w)
+
+	l.StatusLog("blocked")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, l.Flush(ctx), context.DeadlineExceeded)
+	close(w.gate)
+}
+
+func TestShutdown(t *testing.T) {
+	w, other := &slowWriter{}, make(chanWriter, 10)
+	l := NewLogger(WithOutput(&bytes.Buffer{}))
+	l.AddWriter(w, MinLevel(ERROR))
+	l.AddWriter(other)
+
+	l.ErrorLog(fakeErr{}, "before shutdown")
+	require.NoError(t, l.Shutdown(context.Background()))
+	assert.Contains(t, other.next(t), "before shutdown")
+
+	w.lock.Lock()
+	assert.Equal(t, 1, w.lines)
+	assert.True(t, w.closed)
+	w.lock.Unlock()
+
+	l.AddWriter(other)
+	l.ErrorLog(fakeErr{}, "after shutdown")
+	require.NoError(t, l.Flush(context.Background()))
+	assert.Empty(t, other)
+
+	stopped := make(chan struct{})
+	go func() {
+		l.queue.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("workers of queue didn't exit")
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GelfCompression is compression of GELF messages sent over UDP
+type GelfCompression int8
+
+const (
+	// GelfGzip compresses messages with gzip
+	GelfGzip GelfCompression = iota
+	// GelfZlib compresses messages with zlib
+	GelfZlib
+	// GelfNone sends messages without compression
+	GelfNone
+)
+
+const (
+	// gelfChunkSize is default size of UDP datagrams for WAN
+	gelfChunkSize = 1420
+	// gelfChunkHeader is size of header of chunk: magic bytes, id of message, number & count of chunks
+	gelfChunkHeader = 12
+	gelfMaxChunks   = 128
+)
+
+// GelfWriter sends records to Graylog in GELF 1.1 over udp (compressed & chunked) or tcp (null-terminated)
+type GelfWriter struct {
+	network     string
+	addr        string
+	host        string
+	compression GelfCompression
+	chunkSize   int
+	timeout     time.Duration
+
+	lock sync.Mutex
+	conn net.Conn
+}
+
+// GelfOption configures GelfWriter
+type GelfOption func(*GelfWriter)
+
+// GelfHost sets host of messages, os.Hostname() by default
+func GelfHost(host string) GelfOption {
+	return func(w *GelfWriter) {
+		w.host = host
+	}
+}
+
+// GelfCompress sets compression of UDP messages, GelfGzip by default, messages over tcp aren't compressed
+func GelfCompress(compression GelfCompression) GelfOption {
+	return func(w *GelfWriter) {
+		w.compression = compression
+	}
+}
+
+// GelfChunkSize sets max size of UDP datagram, 1420 by default (8154 is suitable for LAN)
+func GelfChunkSize(size int) GelfOption {
+	return func(w *GelfWriter) {
+		w.chunkSize = size
+	}
+}
+
+// GelfTimeout sets timeout of connection & writing, 5 seconds by default
+func GelfTimeout(timeout time.Duration) GelfOption {
+	return func(w *GelfWriter) {
+		w.timeout = timeout
+	}
+}
+
+// NewGelfWriter creates writer to Graylog input at addr, network is udp or tcp,
+// ex. logs.AddWriter(logs.NewGelfWriter("udp", "graylog:12201"))
+func NewGelfWriter(network, addr string, opts ...GelfOption) (*GelfWriter, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, errors.Errorf("unsupported network of GELF '%s'", network)
+	}
+
+	w := &GelfWriter{
+		network:   network,
+		addr:      addr,
+		chunkSize: gelfChunkSize,
+		timeout:   5 * time.Second,
+	}
+	w.host, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.chunkSize <= gelfChunkHeader {
+		return nil, errors.Errorf("chunk size %d is too small", w.chunkSize)
+	}
+
+	return w, nil
+}
+
+// Write sends p as message of INFO record
+func (w *GelfWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord sends rec to Graylog
+func (w *GelfWriter) WriteRecord(rec *Record) error {
+	msg := w.encode(rec)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.isUDP() {
+		return w.sendUDP(msg)
+	}
+
+	return w.sendTCP(append(msg, 0))
+}
+
+// Close closes connection to Graylog
+func (w *GelfWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return errors.Wrap(err, "close GELF connection")
+}
+
+func (w *GelfWriter) isUDP() bool {
+	return strings.HasPrefix(w.network, "udp")
+}
+
+// encode returns rec as GELF message, output of ErrorStack (records with stack) is full_message,
+// messages of wrapped errors are lines of '_error_chain'
+func (w *GelfWriter) encode(rec *Record) []byte {
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+	msg = strings.TrimSpace(stripColors(msg))
+	if msg == "" {
+		msg = "-"
+	}
+
+	t := rec.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteString(`{"version":"1.1","host":`)
+	b.Write(jsonValue(w.host))
+	b.WriteString(`,"short_message":`)
+	b.Write(jsonValue(msg))
+	if len(rec.Stack) > 0 && len(rec.text) > 0 {
+		b.WriteString(`,"full_message":`)
+		b.Write(jsonValue(strings.TrimSpace(stripColors(string(rec.text)))))
+	}
+	b.WriteString(`,"timestamp":`)
+	b.WriteString(strconv.FormatFloat(float64(t.UnixMicro())/1e6, 'f', 6, 64))
+	b.WriteString(`,"level":`)
+	b.WriteString(strconv.Itoa(syslogSeverity(rec.Level)))
+
+	writeGelfField(b, "level_name", rec.Level.String())
+	if rec.File > "" {
+		writeGelfField(b, "file", rec.File)
+		writeGelfField(b, "line", rec.Line)
+	}
+	if rec.Func > "" {
+		writeGelfField(b, "func", rec.Func)
+	}
+	if rec.Err != nil {
+		writeGelfField(b, "error", rec.Err.Error())
+		if chain := errorChain(rec.Err); len(chain) > 1 {
+			writeGelfField(b, "error_chain", strings.Join(chain, "\n"))
+		}
+	}
+	for _, f := range rec.Fields {
+		writeGelfField(b, f.Key, f.Value)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes()
+}
+
+// writeGelfField writes additional field '_key', its value is number or string
+func writeGelfField(b *bytes.Buffer, key string, value any) {
+	name := []byte(key)
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			name[i] = '_'
+		}
+	}
+	// '_id' is reserved by Graylog
+	if string(name) == "id" {
+		name = append(name, '_')
+	}
+
+	b.WriteString(`,"_`)
+	b.Write(name)
+	b.WriteString(`":`)
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		b.Write(jsonValue(value))
+	default:
+		b.Write(jsonValue(fieldValue(value)))
+	}
+}
+
+// compress returns msg compressed according to compression of writer
+func (w *GelfWriter) compress(msg []byte) ([]byte, error) {
+	b := &bytes.Buffer{}
+	var zw io.WriteCloser
+	switch w.compression {
+	case GelfGzip:
+		zw = gzip.NewWriter(b)
+	case GelfZlib:
+		zw = zlib.NewWriter(b)
+	default:
+		return msg, nil
+	}
+
+	if _, err := zw.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (w *GelfWriter) connect() error {
+	if w.conn != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout(w.network, w.addr, w.timeout)
+	if err != nil {
+		return errors.Wrap(err, "connect to GELF input")
+	}
+	w.conn = conn
+
+	return nil
+}
+
+// sendUDP sends compressed msg as one datagram or as chunks
+func (w *GelfWriter) sendUDP(msg []byte) error {
+	msg, err := w.compress(msg)
+	if err != nil {
+		return errors.Wrap(err, "compress GELF message")
+	}
+
+	if err := w.connect(); err != nil {
+		return err
+	}
+
+	if len(msg) <= w.chunkSize {
+		return w.writeConn(msg)
+	}
+
+	size := w.chunkSize - gelfChunkHeader
+	count := (len(msg) + size - 1) / size
+	if count > gelfMaxChunks {
+		return errors.Errorf("GELF message of %d bytes exceeds %d chunks", len(msg), gelfMaxChunks)
+	}
+
+	header := make([]byte, gelfChunkHeader, w.chunkSize)
+	header[0], header[1] = 0x1e, 0x0f
+	binary.BigEndian.PutUint64(header[2:], rand.Uint64())
+	header[11] = byte(count)
+	for i := range count {
+		header[10] = byte(i)
+		chunk := append(header, msg[i*size:min((i+1)*size, len(msg))]...)
+		if err := w.writeConn(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendTCP sends framed msg, broken connection is restored once
+func (w *GelfWriter) sendTCP(msg []byte) error {
+	var err error
+	for range 2 {
+		if err = w.connect(); err != nil {
+			return err
+		}
+
+		if err = w.writeConn(msg); err == nil {
+			return nil
+		}
+
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	return err
+}
+
+func (w *GelfWriter) writeConn(b []byte) error {
+	if w.timeout > 0 {
+		_ = w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	}
+
+	_, err := w.conn.Write(b)
+
+	return errors.Wrap(err, "write GELF message")
+}
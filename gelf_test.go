@@ -0,0 +1,167 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGelfEncode(t *testing.T) {
+	w, err := NewGelfWriter("udp", "localhost:12201", GelfHost("host"))
+	require.NoError(t, err)
+
+	rec := &Record{
+		Time:    time.Unix(1700000000, 123456789),
+		Level:   WARNING,
+		Message: "disk is full",
+		File:    "main.go",
+		Line:    7,
+		Func:    "main.run",
+		Fields:  []Field{F("id", 1), F("free space", 0.5), F("ok", true)},
+	}
+	assert.Equal(t, `{"version":"1.1","host":"host","short_message":"disk is full","timestamp":1700000000.123456,"level":4,`+
+		`"_level_name":"WARNING","_file":"main.go","_line":7,"_func":"main.run","_id_":1,"_free_space":0.5,"_ok":"true"}`,
+		string(w.encode(rec)))
+
+	rec = &Record{
+		Time:    time.Unix(1700000000, 0),
+		Level:   ERROR,
+		Message: "request failed",
+		Err:     errors.Wrap(errors.Wrap(io.EOF, "read body"), "request"),
+	}
+	assert.Equal(t, `{"version":"1.1","host":"host","short_message":"request failed","timestamp":1700000000.000000,"level":3,`+
+		`"_level_name":"ERROR","_error":"request: read body: EOF","_error_chain":"request: read body: EOF\nread body: EOF\nEOF"}`,
+		string(w.encode(rec)))
+
+	_, err = NewGelfWriter("unix", "/tmp/gelf")
+	assert.Error(t, err)
+	_, err = NewGelfWriter("udp", "localhost:12201", GelfChunkSize(12))
+	assert.Error(t, err)
+}
+
+// readGelfChunks reads datagrams of message & joins its chunks
+func readGelfChunks(t *testing.T, conn net.PacketConn) []byte {
+	t.Helper()
+	chunks := make(map[byte][]byte)
+	buf := make([]byte, 65536)
+	for {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+
+		b := append([]byte(nil), buf[:n]...)
+		if !bytes.HasPrefix(b, []byte{0x1e, 0x0f}) {
+			return b
+		}
+
+		chunks[b[10]] = b[12:]
+		if count := int(b[11]); len(chunks) == count {
+			msg := make([]byte, 0)
+			for i := range count {
+				msg = append(msg, chunks[byte(i)]...)
+			}
+			return msg
+		}
+	}
+}
+
+func TestGelfUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w, err := NewGelfWriter("udp", conn.LocalAddr().String(), GelfChunkSize(100))
+	require.NoError(t, err)
+	defer w.Close()
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	l.ErrorStack(errors.New("broken"))
+
+	gz, err := gzip.NewReader(bytes.NewReader(readGelfChunks(t, conn)))
+	require.NoError(t, err)
+	var msg map[string]any
+	require.NoError(t, json.NewDecoder(gz).Decode(&msg))
+
+	assert.True(t, strings.HasPrefix(msg["short_message"].(string), "broken"))
+	assert.Contains(t, msg["full_message"], "[[ERR_STACK]] TestGelfUDP()")
+	assert.Equal(t, float64(3), msg["level"])
+	assert.Equal(t, "gelf_test.go", msg["_file"])
+	assert.Equal(t, "broken", msg["_error"])
+
+	l.WarningLog("failed", F("user", 7))
+	gz, err = gzip.NewReader(bytes.NewReader(readGelfChunks(t, conn)))
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(gz).Decode(&msg))
+	assert.Equal(t, float64(7), msg["_user"])
+
+	w2, err := NewGelfWriter("udp", conn.LocalAddr().String(), GelfCompress(GelfZlib))
+	require.NoError(t, err)
+	defer w2.Close()
+	_, err = w2.Write([]byte("short"))
+	require.NoError(t, err)
+
+	zr, err := zlib.NewReader(bytes.NewReader(readGelfChunks(t, conn)))
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(zr).Decode(&msg))
+	assert.Equal(t, "short", msg["short_message"])
+
+	w3, err := NewGelfWriter("udp", conn.LocalAddr().String(), GelfCompress(GelfNone), GelfChunkSize(13))
+	require.NoError(t, err)
+	assert.ErrorContains(t, w3.WriteRecord(&Record{Level: INFO, Message: strings.Repeat("x", 200)}), "exceeds 128 chunks")
+}
+
+func TestGelfTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := r.ReadString(0)
+			if err != nil {
+				return
+			}
+			messages <- strings.TrimSuffix(msg, "\x00")
+		}
+	}()
+
+	w, err := NewGelfWriter("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer w.Close()
+
+	for _, text := range []string{"first", "second"} {
+		_, err = w.Write([]byte(text))
+		require.NoError(t, err)
+	}
+
+	for _, text := range []string{"first", "second"} {
+		var msg map[string]any
+		require.NoError(t, json.Unmarshal([]byte(<-messages), &msg))
+		assert.Equal(t, text, msg["short_message"])
+		assert.Equal(t, "1.1", msg["version"])
+	}
+}
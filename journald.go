@@ -0,0 +1,215 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JournalSocket is socket of native protocol of systemd-journald
+const JournalSocket = "/run/systemd/journal/socket"
+
+// JournalWriter sends records to systemd-journald with native protocol:
+// PRIORITY, CODE_FILE, CODE_LINE, CODE_FUNC, SYSLOG_IDENTIFIER & fields of record as journal fields.
+// Records exceeding size of datagram are passed with memfd
+type JournalWriter struct {
+	socket     string
+	identifier string
+
+	lock sync.Mutex
+	conn *net.UnixConn
+}
+
+// JournalOption configures JournalWriter
+type JournalOption func(*JournalWriter)
+
+// JournalIdentifier sets SYSLOG_IDENTIFIER of records, name of executable by default
+func JournalIdentifier(identifier string) JournalOption {
+	return func(w *JournalWriter) {
+		w.identifier = identifier
+	}
+}
+
+// JournalSocketPath sets path of socket of journald instead of JournalSocket
+func JournalSocketPath(path string) JournalOption {
+	return func(w *JournalWriter) {
+		w.socket = path
+	}
+}
+
+// NewJournalWriter creates writer to journald, ex. logs.AddWriter(logs.NewJournalWriter())
+func NewJournalWriter(opts ...JournalOption) (*JournalWriter, error) {
+	w := &JournalWriter{
+		socket:     JournalSocket,
+		identifier: filepath.Base(os.Args[0]),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if _, err := os.Stat(w.socket); err != nil {
+		return nil, errors.Wrap(err, "journald socket")
+	}
+
+	return w, nil
+}
+
+// Write sends p as message of INFO record
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord sends rec to journald
+func (w *JournalWriter) WriteRecord(rec *Record) error {
+	payload := w.encode(rec)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var err error
+	// connection is restored once if journald was restarted
+	for range 2 {
+		if w.conn == nil {
+			w.conn, err = net.DialUnix("unixgram", nil, &net.UnixAddr{Name: w.socket, Net: "unixgram"})
+			if err != nil {
+				w.conn = nil
+				return errors.Wrap(err, "connect to journald")
+			}
+		}
+
+		_, err = w.conn.Write(payload)
+		if err == nil {
+			return nil
+		}
+
+		if isTooLarge(err) {
+			return sendJournalFd(w.conn, payload)
+		}
+
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	return errors.Wrap(err, "write to journald")
+}
+
+// Close closes connection to journald
+func (w *JournalWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return errors.Wrap(err, "close journald connection")
+}
+
+// encode returns rec as datagram of native protocol of journald
+func (w *JournalWriter) encode(rec *Record) []byte {
+	b := &bytes.Buffer{}
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+	writeJournalField(b, "MESSAGE", strings.TrimRight(stripColors(msg), "\n"))
+	writeJournalField(b, "PRIORITY", strconv.Itoa(syslogSeverity(rec.Level)))
+	if w.identifier > "" {
+		writeJournalField(b, "SYSLOG_IDENTIFIER", w.identifier)
+	}
+	if rec.File > "" {
+		writeJournalField(b, "CODE_FILE", rec.File)
+		writeJournalField(b, "CODE_LINE", strconv.Itoa(rec.Line))
+	}
+	if rec.Func > "" {
+		writeJournalField(b, "CODE_FUNC", rec.Func)
+	}
+	if rec.Err != nil {
+		writeJournalField(b, "ERROR", rec.Err.Error())
+	}
+
+	for _, f := range rec.Fields {
+		writeJournalField(b, journalFieldName(f.Key), fieldValue(f.Value))
+	}
+
+	return b.Bytes()
+}
+
+// writeJournalField writes field as 'NAME=value' or with binary size if value has new lines
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if strings.ContainsRune(value, '\n') {
+		b.WriteByte('\n')
+		_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	} else {
+		b.WriteByte('=')
+	}
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalReserved are fields written by JournalWriter & fields with special meaning for journald
+var journalReserved = map[string]bool{
+	"MESSAGE":            true,
+	"MESSAGE_ID":         true,
+	"PRIORITY":           true,
+	"ERROR":              true,
+	"ERRNO":              true,
+	"CODE_FILE":          true,
+	"CODE_LINE":          true,
+	"CODE_FUNC":          true,
+	"SYSLOG_FACILITY":    true,
+	"SYSLOG_IDENTIFIER":  true,
+	"SYSLOG_PID":         true,
+	"SYSLOG_TIMESTAMP":   true,
+	"SYSLOG_RAW":         true,
+	"INVOCATION_ID":      true,
+	"USER_INVOCATION_ID": true,
+	"DOCUMENTATION":      true,
+	"TID":                true,
+	"UNIT":               true,
+	"USER_UNIT":          true,
+}
+
+// journalFieldName returns name of journal field from key: upper case letters, digits & '_',
+// not beginning with '_' or digit, up to 64 chars, names of reserved fields get prefix 'F_'
+func journalFieldName(key string) string {
+	b := make([]byte, 0, len(key)+1)
+	for _, c := range []byte(strings.ToUpper(key)) {
+		if c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			b = append(b, c)
+		} else {
+			b = append(b, '_')
+		}
+	}
+
+	name := strings.TrimLeft(string(b), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' || journalReserved[name] {
+		name = "F_" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return name
+}
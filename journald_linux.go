@@ -0,0 +1,64 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// isTooLarge reports whether datagram wasn't sent due to its size
+func isTooLarge(err error) bool {
+	return errors.Is(err, unix.EMSGSIZE) || errors.Is(err, unix.ENOBUFS)
+}
+
+// sendJournalFd passes payload to journald as sealed memfd (or unlinked file of /dev/shm)
+func sendJournalFd(conn *net.UnixConn, payload []byte) error {
+	f, err := journalMemfd()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(payload); err != nil {
+		return errors.Wrap(err, "write journal memfd")
+	}
+
+	// journald requires sealed memfd, file of /dev/shm isn't sealed
+	_, _ = unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL)
+
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return errors.Wrap(err, "journald connection")
+	}
+
+	rights := unix.UnixRights(int(f.Fd()))
+	if err := rc.Write(func(fd uintptr) bool {
+		err = unix.Sendmsg(int(fd), nil, rights, nil, 0)
+		return err != unix.EAGAIN
+	}); err != nil {
+		return errors.Wrap(err, "journald connection")
+	}
+
+	return errors.Wrap(err, "send journal memfd")
+}
+
+func journalMemfd() (*os.File, error) {
+	fd, err := unix.MemfdCreate("logs-journal", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err == nil {
+		return os.NewFile(uintptr(fd), "logs-journal"), nil
+	}
+
+	f, err := os.CreateTemp("/dev/shm", "logs-journal-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create journal memfd")
+	}
+	_ = os.Remove(f.Name())
+
+	return f, nil
+}
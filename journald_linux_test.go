@@ -0,0 +1,74 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// readJournal receives datagram or memfd of record from journald socket
+func readJournal(t *testing.T, conn *net.UnixConn) map[string]string {
+	t.Helper()
+	buf, oob := make([]byte, 1<<16), make([]byte, 1024)
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	require.NoError(t, err)
+	if oobn == 0 {
+		return parseJournal(t, buf[:n])
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	require.NoError(t, err)
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	require.NoError(t, err)
+
+	f := os.NewFile(uintptr(fds[0]), "memfd")
+	defer f.Close()
+	b, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<30))
+	require.NoError(t, err)
+
+	return parseJournal(t, b)
+}
+
+func TestJournalWriter(t *testing.T) {
+	_, err := NewJournalWriter(JournalSocketPath(filepath.Join(t.TempDir(), "none")))
+	assert.Error(t, err)
+
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w, err := NewJournalWriter(JournalSocketPath(socket), JournalIdentifier("test"))
+	require.NoError(t, err)
+	defer w.Close()
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	l.ErrorLog(fakeErr{}, "request failed", F("user", 7))
+
+	fields := readJournal(t, conn)
+	assert.Equal(t, "3", fields["PRIORITY"])
+	assert.Equal(t, "journald_linux_test.go", fields["CODE_FILE"])
+	assert.Equal(t, "logs.TestJournalWriter", fields["CODE_FUNC"])
+	assert.NotEmpty(t, fields["CODE_LINE"])
+	assert.Equal(t, "fake error", fields["ERROR"])
+	assert.Equal(t, "7", fields["USER"])
+
+	large := strings.Repeat("x", 4<<20)
+	_, err = w.Write([]byte(large))
+	require.NoError(t, err)
+	fields = readJournal(t, conn)
+	assert.Equal(t, large, fields["MESSAGE"])
+	assert.Equal(t, "6", fields["PRIORITY"])
+}
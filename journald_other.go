@@ -0,0 +1,23 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package logs
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// isTooLarge reports whether datagram wasn't sent due to its size
+func isTooLarge(error) bool {
+	return false
+}
+
+// sendJournalFd isn't supported without memfd of linux
+func sendJournalFd(*net.UnixConn, []byte) error {
+	return errors.New("journald is supported on linux only")
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseJournal decodes datagram of native protocol of journald
+func parseJournal(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		require.GreaterOrEqual(t, i, 0)
+		name := string(b[:i])
+		if b[i] == '=' {
+			end := bytes.IndexByte(b, '\n')
+			fields[name] = string(b[i+1 : end])
+			b = b[end+1:]
+			continue
+		}
+
+		size := int(binary.LittleEndian.Uint64(b[i+1 : i+9]))
+		fields[name] = string(b[i+9 : i+9+size])
+		require.Equal(t, byte('\n'), b[i+9+size])
+		b = b[i+10+size:]
+	}
+
+	return fields
+}
+
+func TestJournalEncode(t *testing.T) {
+	w := &JournalWriter{identifier: "app"}
+	rec := &Record{
+		Level:   WARNING,
+		Message: "disk\nis full\n",
+		File:    "main.go",
+		Line:    12,
+		Func:    "main.run",
+		Err:     errors.New("no space"),
+		Fields: []Field{F("free space", 0), F("_private", "x"), F("1st", true),
+			F("message", "duplicate"), F("_Priority", 1)},
+	}
+
+	assert.Equal(t, map[string]string{
+		"MESSAGE":           "disk\nis full",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "app",
+		"CODE_FILE":         "main.go",
+		"CODE_LINE":         "12",
+		"CODE_FUNC":         "main.run",
+		"ERROR":             "no space",
+		"FREE_SPACE":        "0",
+		"PRIVATE":           "x",
+		"F_1ST":             "true",
+		"F_MESSAGE":         "duplicate",
+		"F_PRIORITY":        "1",
+	}, parseJournal(t, w.encode(rec)))
+
+	assert.Equal(t, "F_", journalFieldName("__"))
+	assert.Len(t, journalFieldName(strings.Repeat("a", 100)), 64)
+}
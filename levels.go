@@ -0,0 +1,181 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrLevelExists returns RegisterLevel for duplicate name of level
+	ErrLevelExists = errors.New("level already registered")
+	// ErrTooManyLevels returns RegisterLevel when all flags of FgLogWriter are taken by levels
+	ErrTooManyLevels = errors.New("too many levels, flags of levels are exhausted")
+)
+
+type levelInfo struct {
+	name     string
+	severity Level
+	fg       FgLogWriter
+}
+
+var (
+	levelsLock sync.RWMutex
+	levels     = []levelInfo{
+		CRITICAL: {"CRITICAL", CRITICAL, FgCritical},
+		ERROR:    {"ERROR", ERROR, FgErr},
+		WARNING:  {"WARNING", WARNING, FgWarning},
+		NOTICE:   {"NOTICE", NOTICE, FgNotice},
+		INFO:     {"INFO", INFO, FgInfo},
+		DEBUG:    {"DEBUG", DEBUG, FgDebug},
+		TRACE:    {"TRACE", TRACE, FgTrace},
+	}
+	// first flag of custom levels
+	fgCustom = FgTrace + 1
+)
+
+// fgNone is flag of unknown level, it doesn't match any writers
+const fgNone FgLogWriter = -1
+
+// RegisterLevel adds custom level with name & color (0 means output without color),
+// severity is built-in level with the same importance (for example, AUDIT may have severity NOTICE).
+// The new level has own enable switch, prefix & writers in every Logger, use its Fg() for SetWriters
+func RegisterLevel(name string, severity Level, color Color) (Level, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" {
+		return -1, errors.New("empty name of level")
+	}
+
+	if severity < CRITICAL || severity > TRACE {
+		return -1, errors.Errorf("severity %d must be one of built-in levels", severity)
+	}
+
+	if color != 0 && (color < ColorBlack || color > ColorWhite) {
+		return -1, errors.Errorf("color %d must be one of ColorBlack...ColorWhite", color)
+	}
+
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+
+	for _, info := range levels {
+		if info.name == name {
+			return -1, errors.Wrap(ErrLevelExists, name)
+		}
+	}
+
+	level := Level(len(levels))
+	fg := int(fgCustom) + int(level-TRACE-1)
+	if fg > math.MaxInt8 {
+		return -1, errors.Wrap(ErrTooManyLevels, name)
+	}
+
+	levels = append(levels, levelInfo{name, severity, FgLogWriter(fg)})
+	if color > 0 {
+		colors = append(colors, colorSeq(color))
+		boldcolors = append(boldcolors, colorSeqBold(color))
+	} else {
+		colors = append(colors, "")
+		boldcolors = append(boldcolors, "")
+	}
+
+	return level, nil
+}
+
+// LevelByName returns registered level with name, it is case-insensitive as RegisterLevel
+func LevelByName(name string) (Level, bool) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+
+	for i, info := range levels {
+		if info.name == name {
+			return Level(i), true
+		}
+	}
+
+	return -1, false
+}
+
+// Levels returns all registered levels
+func Levels() []Level {
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+
+	list := make([]Level, len(levels))
+	for i := range levels {
+		list[i] = Level(i)
+	}
+
+	return list
+}
+
+func levelsCount() int {
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+
+	return len(levels)
+}
+
+func (l Level) info() (levelInfo, bool) {
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+
+	if l < 0 || int(l) >= len(levels) {
+		return levelInfo{}, false
+	}
+
+	return levels[l], true
+}
+
+// String returns name of level
+func (l Level) String() string {
+	if info, ok := l.info(); ok {
+		return info.name
+	}
+
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// Severity returns built-in level with the same importance
+func (l Level) Severity() Level {
+	if info, ok := l.info(); ok {
+		return info.severity
+	}
+
+	return l
+}
+
+// Fg returns flag of level for SetWriters, DeleteWriters & CustomLog
+func (l Level) Fg() FgLogWriter {
+	if info, ok := l.info(); ok {
+		return info.fg
+	}
+
+	return fgNone
+}
+
+// Levels returns levels of flag, FgAll means all registered levels
+func (fg FgLogWriter) Levels() []Level {
+	if fg == FgAll {
+		return Levels()
+	}
+
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+
+	for i, info := range levels {
+		if info.fg == fg {
+			return []Level{Level(i)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterLevel(t *testing.T) {
+	out := &bytes.Buffer{}
+	all := make(chanWriter, 10)
+	// logger created before registering must know new level too
+	l := NewLogger(WithOutput(out))
+
+	audit, err := RegisterLevel("audit", NOTICE, ColorBlue)
+	require.NoError(t, err)
+
+	_, err = RegisterLevel("AUDIT", INFO, ColorRed)
+	assert.True(t, errors.Is(err, ErrLevelExists))
+	_, err = RegisterLevel("wrong", audit, ColorRed)
+	assert.Error(t, err)
+
+	assert.Equal(t, "AUDIT", audit.String())
+	assert.Equal(t, NOTICE, audit.Severity())
+	byName, ok := LevelByName(" Audit ")
+	assert.True(t, ok)
+	assert.Equal(t, audit, byName)
+	assert.Contains(t, Levels(), audit)
+
+	w := make(chanWriter, 10)
+	l.SetWriters(w, audit.Fg())
+	l.SetWriters(all, FgAll)
+
+	l.Log(audit, "user %s logged in", "admin")
+	assert.Contains(t, w.next(t), "user admin logged in")
+	assert.Contains(t, all.next(t), "user admin logged in")
+	assert.Contains(t, out.String(), "[["+colorSeq(ColorBlue)+"AUDIT"+LogEndColor+"]]")
+
+	l.NoticeLog("notice record")
+	assert.Contains(t, all.next(t), "notice record")
+	assert.Empty(t, w)
+
+	assert.True(t, l.EnableLevel(audit, false))
+	l.Log(audit, "skipped")
+	l.DeleteWriters(w, audit.Fg())
+	l.EnableLevel(audit, true)
+	l.Log(audit, "without writer")
+	assert.Contains(t, all.next(t), "without writer")
+	assert.Empty(t, w)
+
+	l.Log(Level(100), "unknown")
+	assert.Contains(t, all.next(t), "unknown level 100")
+}
+
+func TestRegisterLevelLimits(t *testing.T) {
+	levelsLock.RLock()
+	count := len(levels)
+	levelsLock.RUnlock()
+	t.Cleanup(func() {
+		levelsLock.Lock()
+		defer levelsLock.Unlock()
+
+		levels, colors, boldcolors = levels[:count], colors[:count], boldcolors[:count]
+	})
+
+	_, err := RegisterLevel("bad color", INFO, Color(255))
+	assert.ErrorContains(t, err, "color 255")
+	_, err = RegisterLevel("negative color", INFO, Color(-1))
+	assert.Error(t, err)
+
+	var last Level
+	for i := 0; ; i++ {
+		level, err := RegisterLevel(fmt.Sprintf("limit%d", i), DEBUG, 0)
+		if err != nil {
+			assert.True(t, errors.Is(err, ErrTooManyLevels))
+			break
+		}
+		last = level
+	}
+	assert.Equal(t, FgLogWriter(math.MaxInt8), last.Fg())
+	assert.Equal(t, []Level{last}, last.Fg().Levels())
+}
@@ -0,0 +1,212 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// LevelWriter is a writer registered in Logger with filter of levels of records it receives.
+// Filter may be changed at runtime with SetMinLevel & SetLevels
+type LevelWriter struct {
+	io.Writer
+	lock     sync.RWMutex
+	minLevel Level
+	levels   map[Level]bool
+	encoder  Encoder
+	// logger provides default encoder
+	logger *Logger
+	// id selects worker of Logger which writes records to writer
+	id uint64
+}
+
+var writerIDs atomic.Uint64
+
+// WriterOption configures LevelWriter on AddWriter
+type WriterOption func(*LevelWriter)
+
+// MinLevel sets writer to receive records at or above level (by severity), MinLevel(WARNING) includes CRITICAL & ERROR
+func MinLevel(level Level) WriterOption {
+	return func(w *LevelWriter) {
+		w.SetMinLevel(level)
+	}
+}
+
+// OnlyLevels sets writer to receive records of mentioned levels only
+func OnlyLevels(levels ...Level) WriterOption {
+	return func(w *LevelWriter) {
+		w.SetLevels(levels...)
+	}
+}
+
+// UseEncoder sets encoder of records for writer instead of default encoder of Logger
+func UseEncoder(enc Encoder) WriterOption {
+	return func(w *LevelWriter) {
+		w.SetEncoder(enc)
+	}
+}
+
+// NewLevelWriter creates LevelWriter receiving all levels by default
+func NewLevelWriter(w io.Writer, opts ...WriterOption) *LevelWriter {
+	lw := &LevelWriter{Writer: w, minLevel: TRACE, id: writerIDs.Add(1)}
+	for _, opt := range opts {
+		opt(lw)
+	}
+
+	return lw
+}
+
+// SetMinLevel sets writer to receive records at or above level (by severity)
+func (w *LevelWriter) SetMinLevel(level Level) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.minLevel = level.Severity()
+	w.levels = nil
+}
+
+// SetLevels sets writer to receive records of mentioned levels only
+func (w *LevelWriter) SetLevels(levels ...Level) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.levels = make(map[Level]bool, len(levels))
+	for _, level := range levels {
+		w.levels[level] = true
+	}
+}
+
+// SetEncoder sets encoder of records for writer, nil means default encoder of Logger
+func (w *LevelWriter) SetEncoder(enc Encoder) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.encoder = enc
+}
+
+// Accept reports whether writer receives records of level
+func (w *LevelWriter) Accept(level Level) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.levels != nil {
+		return w.levels[level]
+	}
+
+	return level.Severity() <= w.minLevel
+}
+
+// WriteRecord writes rec to underlying writer as record if it supports records, otherwise encodes rec
+func (w *LevelWriter) WriteRecord(rec *Record) error {
+	if _, ok := w.Writer.(RecordWriter); ok {
+		return writeRecord(w.Writer, rec)
+	}
+
+	w.lock.RLock()
+	enc := w.encoder
+	w.lock.RUnlock()
+
+	if enc == nil && w.logger != nil {
+		enc = w.logger.Encoder()
+	}
+
+	if enc == nil {
+		return writeRecord(w.Writer, rec)
+	}
+
+	b, err := encode(enc, rec)
+	if err != nil {
+		return err
+	}
+
+	n, err := w.Writer.Write(b)
+	if err == nil && n != len(b) {
+		return io.ErrShortWrite
+	}
+
+	return err
+}
+
+// Flush flushes underlying writer if it buffers data
+func (w *LevelWriter) Flush(ctx context.Context) error {
+	return flushWriter(ctx, w.Writer)
+}
+
+// Close closes underlying writer if it is io.Closer
+func (w *LevelWriter) Close() error {
+	return closeWriter(context.Background(), w.Writer)
+}
+
+// removeLevels excludes levels from filter, return true if writer doesn't accept any level anymore
+func (w *LevelWriter) removeLevels(levels ...Level) bool {
+	accepted := make([]Level, 0)
+	for _, level := range Levels() {
+		if w.Accept(level) {
+			accepted = append(accepted, level)
+		}
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.levels = make(map[Level]bool, len(accepted))
+	for _, level := range accepted {
+		w.levels[level] = true
+	}
+	for _, level := range levels {
+		delete(w.levels, level)
+	}
+
+	return len(w.levels) == 0
+}
+
+// levelFilter is implemented by writers which receive records of some levels only
+type levelFilter interface {
+	Accept(level Level) bool
+}
+
+// levelRoute writes output of level logger to writers of Logger accepting the level
+type levelRoute struct {
+	level   Level
+	writers *MultiWriter
+}
+
+func (r levelRoute) Write(p []byte) (int, error) {
+	return r.writers.WriteLevel(r.level, p)
+}
+
+func (r levelRoute) WriteRecord(rec *Record) error {
+	return r.writers.WriteRecord(rec)
+}
+
+// AddWriter adds writer to receive records of all levels or levels matching options (MinLevel, OnlyLevels)
+func AddWriter(w io.Writer, opts ...WriterOption) *LevelWriter {
+	return Default().AddWriter(w, opts...)
+}
+
+// RemoveWriter removes all registrations of writer
+func RemoveWriter(w io.Writer) {
+	Default().RemoveWriter(w)
+}
+
+// AddWriter adds writer to receive records of all levels or levels matching options (MinLevel, OnlyLevels)
+func (l *Logger) AddWriter(w io.Writer, opts ...WriterOption) *LevelWriter {
+	lw := NewLevelWriter(w, opts...)
+	lw.logger = l
+	l.writers.Append(lw)
+
+	return lw
+}
+
+// RemoveWriter removes all registrations of writer
+func (l *Logger) RemoveWriter(w io.Writer) {
+	l.writers.removeFunc(func(item io.Writer) bool {
+		lw, ok := item.(*LevelWriter)
+		return item == w || ok && lw.Writer == w
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddWriterMinLevel(t *testing.T) {
+	w := make(chanWriter, 10)
+	l := NewLogger(WithOutput(&bytes.Buffer{}), WithDebug(true))
+	lw := l.AddWriter(w, MinLevel(WARNING))
+
+	l.DebugLog("debug record")
+	l.StatusLog("info record")
+	l.WarningLog("warning record")
+	assert.Contains(t, w.next(t), "warning record")
+	l.ErrorLog(fakeErr{}, "error record")
+	assert.Contains(t, w.next(t), "error record")
+	assert.Empty(t, w)
+
+	lw.SetMinLevel(DEBUG)
+	l.DebugLog("debug after change")
+	assert.Contains(t, w.next(t), "debug after change")
+	l.TraceLog("trace record")
+
+	lw.SetLevels(INFO)
+	l.WarningLog("skipped warning")
+	l.StatusLog("info after change")
+	assert.Contains(t, w.next(t), "info after change")
+	assert.Empty(t, w)
+
+	l.RemoveWriter(w)
+	assert.Empty(t, l.writers.writers)
+}
+
+func TestSetWritersSingleEntry(t *testing.T) {
+	w := make(chanWriter, 10)
+	l := NewLogger(WithOutput(&bytes.Buffer{}), WithDebug(true))
+
+	l.SetWriters(w, FgAll)
+	assert.Len(t, l.writers.writers, 1)
+
+	l.DeleteWriters(w, FgInfo)
+	l.StatusLog("skipped info")
+	l.DebugLog("debug record")
+	assert.Contains(t, w.next(t), "debug record")
+
+	l.DeleteWriters(w, FgErr, FgCritical, FgWarning, FgNotice, FgDebug, FgTrace)
+	for _, level := range Levels() {
+		if level > TRACE {
+			l.DeleteWriters(w, level.Fg())
+		}
+	}
+	assert.Empty(t, l.writers.writers)
+
+	l.SetWriters(w, FgErr, FgInfo)
+	assert.Len(t, l.writers.writers, 1)
+	assert.True(t, l.writers.writers[0].(*LevelWriter).Accept(INFO))
+	assert.False(t, l.writers.writers[0].(*LevelWriter).Accept(WARNING))
+}
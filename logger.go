@@ -0,0 +1,463 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"flag"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+)
+
+// Logger is an independent set of level loggers with its own writers, flags and error tracker config.
+// Package functions (ErrorLog, StatusLog, DebugLog, ...) use the default Logger, see Default & SetDefault
+type Logger struct {
+	loggers        []*wrapKitLogger
+	lock           sync.RWMutex
+	writers        *MultiWriter
+	queue          *dispatcher
+	encoder        Encoder
+	consoleEncoder Encoder
+	stackBeginWith atomic.Int64
+	sentry         atomic.Pointer[sentryTarget]
+}
+
+// sentryTarget is Sentry hub of Logger with settings of links to its issues
+type sentryTarget struct {
+	hub *sentry.Hub
+	dsn string
+	org string
+}
+
+// Option configures Logger on creating
+type Option func(*Logger)
+
+// WithOutput sets console output of all levels of the logger (os.Stdout by default)
+func WithOutput(w io.Writer) Option {
+	return func(l *Logger) {
+		for _, logger := range l.allLoggers() {
+			logger.SetOutput(w)
+		}
+	}
+}
+
+// WithLogFlags sets flags of the logger (log.Lshortfile | log.Ltime by default)
+func WithLogFlags(f int) Option {
+	return func(l *Logger) {
+		l.SetLogFlags(f)
+	}
+}
+
+// WithDebug enables or disables DebugLog output of the logger
+func WithDebug(d bool) Option {
+	return WithLevel(DEBUG, d)
+}
+
+// WithStatus enables or disables StatusLog output of the logger
+func WithStatus(s bool) Option {
+	return WithLevel(INFO, s)
+}
+
+// WithLevel enables or disables output of level
+func WithLevel(level Level, enabled bool) Option {
+	return func(l *Logger) {
+		l.EnableLevel(level, enabled)
+	}
+}
+
+// WithLevelPrefix sets console prefix of level
+func WithLevelPrefix(level Level, prefix string) Option {
+	return func(l *Logger) {
+		l.SetLevelPrefix(level, prefix)
+	}
+}
+
+// WithStackBeginWith sets the first frame of runtime stack for ErrorStack
+func WithStackBeginWith(s int) Option {
+	return func(l *Logger) {
+		l.stackBeginWith.Store(int64(s))
+	}
+}
+
+// WithWriters adds newWriter to the writers of mentioned logFlags
+func WithWriters(newWriter io.Writer, logFlags ...FgLogWriter) Option {
+	return func(l *Logger) {
+		l.SetWriters(newWriter, logFlags...)
+	}
+}
+
+// WithSentryHub sends errors of the logger to hub, org is used for links to the issues
+func WithSentryHub(hub *sentry.Hub, org string) Option {
+	return func(l *Logger) {
+		dsn := ""
+		if client := hub.Client(); client != nil {
+			dsn = client.Options().Dsn
+		}
+		l.setSentry(hub, dsn, org)
+	}
+}
+
+// WithEncoder sets default encoder of records for writers, TextEncoder is used if it is not set
+func WithEncoder(enc Encoder) Option {
+	return func(l *Logger) {
+		l.SetEncoder(enc)
+	}
+}
+
+// WithConsoleEncoder sets encoder of records for console output instead of text output of log.Logger
+func WithConsoleEncoder(enc Encoder) Option {
+	return func(l *Logger) {
+		l.SetConsoleEncoder(enc)
+	}
+}
+
+// WithQueue sets options of queue of records for writers,
+// by default one worker delivers records & logging waits when 1024 records are in queue
+func WithQueue(opts QueueOptions) Option {
+	return func(l *Logger) {
+		l.setQueue(newDispatcher(opts, l.deliver, l.notify))
+	}
+}
+
+// WithParallelWriters turns on writing to every writer by own goroutine, so slow writer doesn't delay others,
+// see MultiWriter.Parallel
+func WithParallelWriters(timeout time.Duration, queueSize int) Option {
+	return func(l *Logger) {
+		l.writers.Parallel(timeout, queueSize)
+	}
+}
+
+// NewLogger creates Logger with own writers & settings
+func NewLogger(opts ...Option) *Logger {
+	l := &Logger{
+		writers: &MultiWriter{},
+	}
+	l.stackBeginWith.Store(1)
+	l.queue = newDispatcher(defaultQueueOptions, l.deliver, l.notify)
+	for level := range levelsCount() {
+		l.loggers = append(l.loggers, l.newLevelLogger(Level(level)))
+	}
+	l.logger(TRACE).enabled.Store(false)
+	l.logger(DEBUG).enabled.Store(false)
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+var defaultLogger atomic.Pointer[Logger]
+
+func init() {
+	defaultLogger.Store(NewLogger())
+
+	// command line flags switch levels of default logger when they are parsed
+	flag.BoolFunc("debug", "debug mode", levelFlag(DEBUG))
+	flag.BoolFunc("status", "status mode (default true)", levelFlag(INFO))
+}
+
+// levelFlag returns setter of command line flag which enables or disables level of default logger
+func levelFlag(level Level) func(string) error {
+	return func(s string) error {
+		enabled, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		Default().EnableLevel(level, enabled)
+
+		return nil
+	}
+}
+
+// callDepth of level loggers from levelLog
+const levelCallDepth = 4
+
+func (l *Logger) newLevelLogger(level Level) *wrapKitLogger {
+	logger := NewWrapKitLogger(levelPrefix(level), levelCallDepth)
+	logger.level = level
+	logger.toOther = levelRoute{level, l.writers}
+	logger.queue = l.queue
+
+	return logger
+}
+
+func levelPrefix(level Level) string {
+	color := levelColor(level)
+	if color == "" {
+		return level.String()
+	}
+
+	return color + level.String() + LogEndColor
+}
+
+// logger returns logger of level, it creates loggers of levels registered after creating Logger
+func (l *Logger) logger(level Level) *wrapKitLogger {
+	l.lock.RLock()
+	if int(level) < len(l.loggers) {
+		defer l.lock.RUnlock()
+		return l.loggers[level]
+	}
+	l.lock.RUnlock()
+
+	if level < 0 || int(level) >= levelsCount() {
+		panic(errors.Errorf("unknown level %d", level))
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for i := Level(len(l.loggers)); i <= level; i++ {
+		logger := l.newLevelLogger(i)
+		logger.SetOutput(l.loggers[0].Writer())
+		logger.SetFlags(l.loggers[0].Flags())
+		logger.encoder = l.consoleEncoder
+		l.loggers = append(l.loggers, logger)
+	}
+
+	return l.loggers[level]
+}
+
+// setQueue replaces queue of records, it waits until workers of previous queue deliver its records & exit
+func (l *Logger) setQueue(queue *dispatcher) {
+	for _, logger := range l.allLoggers() {
+		logger.lock.Lock()
+		logger.queue = queue
+		logger.lock.Unlock()
+	}
+
+	l.lock.Lock()
+	old := l.queue
+	l.queue = queue
+	l.lock.Unlock()
+
+	if old != nil {
+		old.close()
+		old.workers.Wait()
+	}
+}
+
+// deliver writes rec to writers of worker shard
+func (l *Logger) deliver(shard, shards int, rec *Record) {
+	err := l.writers.writeRecordIf(rec, func(w io.Writer) bool {
+		id := uint64(0)
+		if lw, ok := w.(*LevelWriter); ok {
+			id = lw.id
+		}
+
+		return id%uint64(shards) == uint64(shard)
+	})
+	if err != nil {
+		l.logger(rec.Level).printError("Write toOther: %v,", err)
+	}
+}
+
+// notify outputs problems of delivery of records to console
+func (l *Logger) notify(format string, args ...any) {
+	l.logger(WARNING).printError(format, args...)
+}
+
+// Dropped returns count of records which queues of workers dropped by overflow policy
+func (l *Logger) Dropped() uint64 {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return l.queue.dropped()
+}
+
+// allLoggers returns loggers of all registered levels
+func (l *Logger) allLoggers() []*wrapKitLogger {
+	l.logger(Level(levelsCount() - 1))
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return append([]*wrapKitLogger(nil), l.loggers...)
+}
+
+// Default returns the logger used by package functions
+func Default() *Logger {
+	return defaultLogger.Load()
+}
+
+// SetDefault replaces the logger used by package functions, return old value
+func SetDefault(l *Logger) *Logger {
+	if l == nil {
+		return Default()
+	}
+
+	return defaultLogger.Swap(l)
+}
+
+// SetDebug set debug level for log, return old value
+func (l *Logger) SetDebug(d bool) bool {
+	return l.EnableLevel(DEBUG, d)
+}
+
+// SetStatus set status level for log, return old value
+func (l *Logger) SetStatus(s bool) bool {
+	return l.EnableLevel(INFO, s)
+}
+
+// EnableLevel enables or disables output of level, return old value
+func (l *Logger) EnableLevel(level Level, enabled bool) bool {
+	return l.logger(level).enabled.Swap(enabled)
+}
+
+// IsEnabled reports whether output of level is enabled
+func (l *Logger) IsEnabled(level Level) bool {
+	return l.logger(level).enabled.Load()
+}
+
+// SetLevelPrefix sets console prefix of level, it may contain color sequences
+func (l *Logger) SetLevelPrefix(level Level, prefix string) {
+	logger := l.logger(level)
+	logger.typeLog = prefix
+	logger.SetPrefix("[[" + prefix + "]]")
+}
+
+// SetWriters adds newWriter for levels of logFlags, it is the same as AddWriter with OnlyLevels
+func (l *Logger) SetWriters(newWriter io.Writer, logFlags ...FgLogWriter) {
+	levels := make([]Level, 0, len(logFlags))
+	for _, logFlag := range logFlags {
+		if logFlag == FgAll {
+			l.AddWriter(newWriter)
+			return
+		}
+		levels = append(levels, logFlag.Levels()...)
+	}
+
+	if len(levels) > 0 {
+		l.AddWriter(newWriter, OnlyLevels(levels...))
+	}
+}
+
+// DeleteWriters deletes mentioned writer from writers for mentioned logFlag
+func (l *Logger) DeleteWriters(writerToDelete io.Writer, logFlags ...FgLogWriter) {
+	levels := make([]Level, 0, len(logFlags))
+	for _, logFlag := range logFlags {
+		if logFlag == FgAll {
+			l.RemoveWriter(writerToDelete)
+			return
+		}
+		levels = append(levels, logFlag.Levels()...)
+	}
+
+	l.writers.removeFunc(func(item io.Writer) bool {
+		lw, ok := item.(*LevelWriter)
+		return ok && lw.Writer == writerToDelete && lw.removeLevels(levels...)
+	})
+}
+
+// SetEncoder sets default encoder of records for writers which don't have own encoder, nil means TextEncoder
+func (l *Logger) SetEncoder(enc Encoder) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.encoder = enc
+}
+
+// Encoder returns default encoder of records for writers
+func (l *Logger) Encoder() Encoder {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return l.encoder
+}
+
+// SetConsoleEncoder sets encoder of records for console output, nil means text output of log.Logger
+func (l *Logger) SetConsoleEncoder(enc Encoder) {
+	for _, logger := range l.allLoggers() {
+		logger.lock.Lock()
+		logger.encoder = enc
+		logger.lock.Unlock()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.consoleEncoder = enc
+}
+
+// SetSentry creates own sentry client of the logger for output errors
+func (l *Logger) SetSentry(dsn string, org string) error {
+	client, err := sentry.NewClient(sentry.ClientOptions{Dsn: dsn})
+	if err != nil {
+		return errors.Wrap(err, "sentry.NewClient")
+	}
+
+	l.setSentry(sentry.NewHub(client, sentry.NewScope()), dsn, org)
+
+	return nil
+}
+
+// setSentry replaces sentry target of logger, keeps previous dsn if dsn is empty
+func (l *Logger) setSentry(hub *sentry.Hub, dsn string, org string) {
+	for {
+		old := l.sentry.Load()
+		target := &sentryTarget{hub: hub, dsn: dsn, org: org}
+		if dsn == "" && old != nil {
+			target.dsn = old.dsn
+		}
+
+		if l.sentry.CompareAndSwap(old, target) {
+			return
+		}
+	}
+}
+
+// sentryHub returns sentry hub of logger or nil
+func (l *Logger) sentryHub() *sentry.Hub {
+	if target := l.sentry.Load(); target != nil {
+		return target.hub
+	}
+
+	return nil
+}
+
+// captureSentry sends err to sentry hub of logger, return format of link to the issue, its args & the hub
+func (l *Logger) captureSentry(err error) (string, []any, *sentry.Hub) {
+	target := l.sentry.Load()
+	if target == nil || target.hub == nil {
+		return "", nil, nil
+	}
+
+	eventID := ""
+	if id := target.hub.CaptureException(errors.Wrap(err, "sentry")); id != nil {
+		eventID = string(*id)
+	}
+
+	if target.dsn > "" {
+		return target.dsn + "/%s/?query=%s", []any{target.org, eventID}, target.hub
+	}
+
+	return "https://sentry.io/organizations/%s/?query=%s", []any{target.org, eventID}, target.hub
+}
+
+// SetLogFlags set logger flags & return old flags
+func (l *Logger) SetLogFlags(f int) int {
+	flags := l.logger(ERROR).Flags()
+
+	for _, logger := range l.allLoggers() {
+		logger.SetFlags(f)
+	}
+
+	return flags
+}
+
+// SetStackBeginWith set stackBeginWith level for log, return old value
+func (l *Logger) SetStackBeginWith(s int) int {
+	return int(l.stackBeginWith.Swap(int64(s)))
+}
+
+// Writer returns console output of the logger
+func (l *Logger) Writer() io.Writer {
+	return l.logger(ERROR).Writer()
+}
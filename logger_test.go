@@ -0,0 +1,173 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chanWriter chan string
+
+func (c chanWriter) Write(b []byte) (int, error) {
+	c <- string(b)
+
+	return len(b), nil
+}
+
+func (c chanWriter) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case s := <-c:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("writer didn't receive record")
+	}
+
+	return ""
+}
+
+func TestLoggerInstances(t *testing.T) {
+	first, second := make(chanWriter, 10), make(chanWriter, 10)
+	out := &bytes.Buffer{}
+
+	l1 := NewLogger(WithWriters(first, FgErr), WithOutput(out))
+	l2 := NewLogger(WithWriters(second, FgAll), WithDebug(true))
+
+	l1.ErrorLog(fakeErr{}, "first logger")
+	l2.DebugLog("second logger")
+
+	assert.Contains(t, first.next(t), "first logger")
+	assert.Contains(t, second.next(t), "second logger")
+	assert.Contains(t, out.String(), "first logger")
+	assert.Empty(t, first)
+	assert.Empty(t, second)
+
+	assert.False(t, l1.SetDebug(true))
+	assert.True(t, l2.SetStatus(false))
+	assert.True(t, Default().IsEnabled(INFO))
+}
+
+func TestEnableLevelConcurrent(t *testing.T) {
+	l := NewLogger(WithOutput(io.Discard))
+	h := slog.New(l.NewSlogHandler())
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 200 {
+			l.SetDebug(i%2 == 0)
+			l.EnableLevel(WARNING, i%2 == 1)
+		}
+	})
+	for range 2 {
+		wg.Go(func() {
+			for range 200 {
+				l.DebugLog("debug")
+				l.WarningLog("warning")
+				h.Debug("slog debug")
+				_ = l.IsEnabled(DEBUG)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestSentryConcurrent(t *testing.T) {
+	client, err := sentry.NewClient(sentry.ClientOptions{})
+	require.NoError(t, err)
+	hub := sentry.NewHub(client, sentry.NewScope())
+
+	l := NewLogger(WithOutput(io.Discard))
+	h := slog.New(l.NewSlogHandler())
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 100 {
+			l.setSentry(hub, "", "org")
+			l.SetStackBeginWith(i % 3)
+		}
+	})
+	for range 2 {
+		wg.Go(func() {
+			for range 100 {
+				l.ErrorLog(errors.New("error"))
+				l.ErrorStack(errors.New("stack"))
+				h.Error("slog error")
+			}
+		})
+	}
+	wg.Wait()
+
+	require.NoError(t, l.Flush(context.Background()))
+	assert.Equal(t, "org", l.sentry.Load().org)
+}
+
+func TestLevelFlags(t *testing.T) {
+	l := NewLogger(WithOutput(io.Discard))
+	old := SetDefault(l)
+	defer SetDefault(old)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.BoolFunc("debug", "debug mode", levelFlag(DEBUG))
+	fs.BoolFunc("status", "status mode", levelFlag(INFO))
+	require.NoError(t, fs.Parse([]string{"-debug", "-status=false"}))
+
+	assert.True(t, l.IsEnabled(DEBUG))
+	assert.False(t, l.IsEnabled(INFO))
+	assert.Error(t, fs.Parse([]string{"-debug=maybe"}))
+}
+
+func TestSetDefault(t *testing.T) {
+	w := make(chanWriter, 10)
+	l := NewLogger(WithWriters(w, FgInfo), WithOutput(&bytes.Buffer{}))
+
+	old := SetDefault(l)
+	defer SetDefault(old)
+
+	StatusLog("through default")
+	assert.True(t, strings.HasSuffix(w.next(t), "through default"))
+	assert.Equal(t, l, Default())
+}
+
+func TestLevelLogs(t *testing.T) {
+	writers := map[FgLogWriter]chanWriter{}
+	opts := []Option{WithOutput(&bytes.Buffer{}), WithLevel(TRACE, true)}
+	for _, fg := range []FgLogWriter{FgCritical, FgWarning, FgNotice, FgTrace} {
+		writers[fg] = make(chanWriter, 10)
+		opts = append(opts, WithWriters(writers[fg], fg))
+	}
+	l := NewLogger(opts...)
+
+	l.CriticalLog(fakeErr{}, "critical record")
+	l.WarningLog("warning record")
+	l.NoticeLog("notice record")
+	l.TraceLog("trace record")
+
+	assert.Contains(t, writers[FgCritical].next(t), "critical record")
+	assert.Contains(t, writers[FgWarning].next(t), "warning record")
+	assert.Contains(t, writers[FgNotice].next(t), "notice record")
+	assert.Contains(t, writers[FgTrace].next(t), "trace record")
+
+	assert.True(t, l.EnableLevel(WARNING, false))
+	l.WarningLog("skipped record")
+	l.NoticeLog("next notice")
+	assert.Contains(t, writers[FgNotice].next(t), "next notice")
+	assert.Empty(t, writers[FgWarning])
+
+	assert.Equal(t, "TRACE", TRACE.String())
+	assert.Equal(t, "Level(42)", Level(42).String())
+}
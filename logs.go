@@ -7,27 +7,19 @@ package logs
 
 import (
 	"bytes"
-	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/pkg/errors"
 )
 
-var (
-	fDebug   = flag.Bool("debug", false, "debug mode")
-	fStatus  = flag.Bool("status", true, "status mode")
-	logErr   = NewWrapKitLogger(colors[ERROR]+"ERROR"+LogEndColor, 1)
-	logStat  = NewWrapKitLogger("INFO", 3)
-	logDebug = NewWrapKitLogger(colors[DEBUG]+"DEBUG"+LogEndColor, 3)
-)
-
 // LogsType - interface for print logs record
 type LogsType interface {
 	PrintToLogs(w io.Writer) error
@@ -40,40 +32,59 @@ type wrapKitLogger struct {
 	fileName  string
 	funcName  string
 	typeLog   string
-	toSentry  bool
-	sentryDsn string
-	sentryOrg string
+	level     Level
+	enabled   atomic.Bool
+	encoder   Encoder
 	toOther   io.Writer
+	queue     *dispatcher
 	lock      sync.RWMutex
+	// printLock serializes printError, it doesn't take lock, which may be held by producer waiting for queue
+	printLock sync.Mutex
 }
 
 const logFlags = log.Lshortfile | log.Ltime
 
-var stackBeginWith = 1
-
 func NewWrapKitLogger(pref string, depth int) *wrapKitLogger {
-	return &wrapKitLogger{
+	logger := &wrapKitLogger{
 		Logger:    log.New(os.Stdout, "[["+pref+"]]", logFlags),
 		typeLog:   pref,
+		level:     INFO,
 		callDepth: depth,
 		toOther:   &MultiWriter{lock: sync.RWMutex{}},
 	}
+	logger.enabled.Store(true)
+	logger.queue = newDispatcher(defaultQueueOptions,
+		func(_, _ int, rec *Record) {
+			if err := writeRecord(logger.toOther, rec); err != nil {
+				logger.printError("Write toOther: %v,", err)
+			}
+		},
+		logger.printError)
+
+	return logger
+}
+
+// printError outputs errors of delivery records to console
+func (logger *wrapKitLogger) printError(format string, args ...any) {
+	logger.printLock.Lock()
+	defer logger.printLock.Unlock()
+
+	fmt.Fprintln(logger.Writer(), logger.header(&Record{Time: time.Now()})+fmt.Sprintf(format, args...))
 }
 
 // SetDebug set debug level for log, return old value
 func SetDebug(d bool) bool {
-	old := *fDebug
-	*fDebug = d
-
-	return old
+	return Default().SetDebug(d)
 }
 
 // SetStatus set status level for log, return old value
 func SetStatus(s bool) bool {
-	old := *fStatus
-	*fStatus = s
+	return Default().SetStatus(s)
+}
 
-	return old
+// EnableLevel enables or disables output of level, return old value
+func EnableLevel(level Level, enabled bool) bool {
+	return Default().EnableLevel(level, enabled)
 }
 
 type FgLogWriter int8
@@ -83,54 +94,20 @@ const (
 	FgErr
 	FgInfo
 	FgDebug
+	FgCritical
+	FgWarning
+	FgNotice
+	FgTrace
 )
 
-func (logger *wrapKitLogger) addWriter(newWriters ...io.Writer) {
-	logger.toOther.(*MultiWriter).Append(newWriters...)
-}
-
-func (logger *wrapKitLogger) deleteWriter(writersToDelete ...io.Writer) {
-	logger.toOther.(*MultiWriter).Remove(writersToDelete...)
-}
-
 // SetWriters for logs
 func SetWriters(newWriter io.Writer, logFlags ...FgLogWriter) {
-	// todo: можно поменять местами аргументы и дать возможность добавлять неограниченное количество врайтеров
-
-	for _, logFlag := range logFlags {
-		switch logFlag {
-		case FgAll:
-			logErr.addWriter(newWriter)
-			logStat.addWriter(newWriter)
-			logDebug.addWriter(newWriter)
-		case FgErr:
-			logErr.addWriter(newWriter)
-		case FgInfo:
-			logStat.addWriter(newWriter)
-		case FgDebug:
-			logDebug.addWriter(newWriter)
-		}
-	}
-
+	Default().SetWriters(newWriter, logFlags...)
 }
 
 // DeleteWriters deletes mentioned writer from writers for mentioned logFlag
 func DeleteWriters(writerToDelete io.Writer, logFlags ...FgLogWriter) {
-
-	for _, logFlag := range logFlags {
-		switch logFlag {
-		case FgAll:
-			logErr.deleteWriter(writerToDelete)
-			logStat.deleteWriter(writerToDelete)
-			logDebug.deleteWriter(writerToDelete)
-		case FgErr:
-			logErr.deleteWriter(writerToDelete)
-		case FgInfo:
-			logStat.deleteWriter(writerToDelete)
-		case FgDebug:
-			logDebug.deleteWriter(writerToDelete)
-		}
-	}
+	Default().DeleteWriters(writerToDelete, logFlags...)
 }
 
 // SetSentry set SetSentry output for error
@@ -140,46 +117,78 @@ func SetSentry(dsn string, org string) error {
 		return errors.Wrap(err, "sentry.Init")
 	}
 
-	logErr.toSentry = true
-	logErr.sentryOrg = org
-	if dsn > "" {
-		logErr.sentryDsn = dsn
-	}
+	Default().setSentry(sentry.CurrentHub(), dsn, org)
 
 	return nil
 }
 
-// SetLogFlags set logger flags & return old flags
-func SetLogFlags(f int) int {
+// SetEncoder sets default encoder of records for writers, ex. SetEncoder(JSONEncoder{})
+func SetEncoder(enc Encoder) {
+	Default().SetEncoder(enc)
+}
 
-	flags := logErr.Flags()
+// SetConsoleEncoder sets encoder of records for console output, ex. SetConsoleEncoder(JSONEncoder{})
+func SetConsoleEncoder(enc Encoder) {
+	Default().SetConsoleEncoder(enc)
+}
 
-	logErr.SetFlags(f)
-	logDebug.SetFlags(f)
-	logStat.SetFlags(f)
+// Dropped returns count of records which queues of default logger dropped by overflow policy
+func Dropped() uint64 {
+	return Default().Dropped()
+}
 
-	return flags
+// SetLogFlags set logger flags & return old flags
+func SetLogFlags(f int) int {
+	return Default().SetLogFlags(f)
 }
 
 // SetStackBeginWith set stackBeginWith level for log, return old value
 func SetStackBeginWith(s int) int {
-	old := stackBeginWith
-	stackBeginWith = s
-
-	return old
+	return Default().SetStackBeginWith(s)
 }
 
 type logMess struct {
-	Message string    `json:"message"`
-	Now     time.Time `json:"@timestamp"`
-	Level   string    `json:"level"`
+	Message string       `json:"message"`
+	Now     time.Time    `json:"@timestamp"`
+	Level   string       `json:"level"`
+	File    string       `json:"file,omitempty"`
+	Line    int          `json:"line,omitempty"`
+	Func    string       `json:"func,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Errors  []string     `json:"error_chain,omitempty"`
+	Stack   []StackFrame `json:"stack,omitempty"`
+	fields  []Field
 }
 
 func NewlogMess(mess string, logger *wrapKitLogger) *logMess {
-	return &logMess{mess, time.Now(), logger.typeLog}
+	return &logMess{Message: mess, Now: time.Now(), Level: logger.level.String()}
+}
+
+func newRecordMess(rec *Record) *logMess {
+	m := &logMess{
+		Message: stripColors(rec.Message),
+		Now:     rec.Time,
+		Level:   rec.Level.String(),
+		File:    rec.File,
+		Line:    rec.Line,
+		Func:    rec.Func,
+		Stack:   rec.Stack,
+		fields:  rec.Fields,
+	}
+	if rec.Err != nil {
+		m.Error = rec.Err.Error()
+		if chain := errorChain(rec.Err); len(chain) > 1 {
+			m.Errors = chain
+		}
+	}
+
+	return m
 }
 
 func (logger *wrapKitLogger) Printf(vars ...any) {
+	if len(vars) == 0 {
+		return
+	}
 
 	checkPrint, checkType := vars[0].(errLogPrint)
 
@@ -187,39 +196,97 @@ func (logger *wrapKitLogger) Printf(vars ...any) {
 		vars = vars[1:]
 	}
 
+	vars, fields := splitFields(vars)
 	w := bytes.NewBuffer(nil)
 	writeFormatArgs(w, vars...)
-	if checkType && bool(checkPrint) {
-		fmt.Println(w.String())
+	rec := Record{
+		Message: w.String(),
+		Fields:  fields,
+	}
+
+	raw := checkType && bool(checkPrint)
+	if !raw {
+		rec.File, rec.Line, rec.Func = logger.fileName, logger.line, logger.funcName
+	}
+
+	logger.output(logger.callDepth+1, rec, w.String(), raw)
+}
+
+// output writes text to console & rec to other writers,
+// raw text is printed as is, otherwise log.Logger adds prefix, time & caller from calldepth
+func (logger *wrapKitLogger) output(calldepth int, rec Record, text string, raw bool) {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	rec.Level = logger.level
+
+	w := bytes.NewBufferString(text)
+	writeFields(w, rec.Fields)
+	if logger.encoder != nil {
+		rec.text = w.Bytes()
+		if b, err := encode(logger.encoder, &rec); err != nil {
+			fmt.Fprintln(logger.Writer(), err)
+		} else {
+			_, _ = logger.Writer().Write(b)
+		}
+	} else if raw {
+		fmt.Fprintln(logger.Writer(), w.String())
 	} else {
-		_ = logger.Output(logger.callDepth, w.String())
+		_ = logger.Output(calldepth, w.String())
 	}
 
 	if logger.toOther != nil && w.Len() > 0 {
-		if !(checkType && bool(checkPrint)) {
-			msg := w.Bytes()
-			w.Reset()
-			fmt.Fprintf(w, "%s%s:%d %s",
-				timeLogFormat(),
-				logger.fileName,
-				logger.line,
-				msg)
+		rec.text = w.Bytes()
+		if !raw {
+			rec.text = fmt.Appendf(nil, "%s%s:%d %s",
+				logger.timeLogFormat(),
+				rec.File,
+				rec.Line,
+				w.Bytes())
 		}
 
-		go func() {
-			defer func() {
-				if err := recover(); err != nil {
-					_ = logger.Output(logger.callDepth, fmt.Sprintf("recover: %v,", err))
-				}
-			}()
-			_, err := logger.toOther.Write(w.Bytes())
-			if err != nil {
-				_ = logger.Output(logger.callDepth, fmt.Sprintf("Write toOther: %v,", err))
-			}
-		}()
+		logger.queue.push(&rec)
 	}
 }
 
+// header formats prefix, time & caller of rec as log.Logger does it according to its flags
+func (logger *wrapKitLogger) header(rec *Record) string {
+	flags := logger.Flags()
+	b := &strings.Builder{}
+	if flags&log.Lmsgprefix == 0 {
+		b.WriteString(logger.Prefix())
+	}
+
+	t := rec.Time
+	if flags&log.LUTC != 0 {
+		t = t.UTC()
+	}
+	if flags&log.Ldate != 0 {
+		b.WriteString(t.Format("2006/01/02 "))
+	}
+	if flags&log.Lmicroseconds != 0 {
+		b.WriteString(t.Format("15:04:05.000000 "))
+	} else if flags&log.Ltime != 0 {
+		b.WriteString(t.Format("15:04:05 "))
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 && rec.File > "" {
+		fmt.Fprintf(b, "%s:%d: ", rec.File, rec.Line)
+	}
+
+	if flags&log.Lmsgprefix != 0 {
+		b.WriteString(logger.Prefix())
+	}
+
+	return b.String()
+}
+
+func formatArgs(args ...any) string {
+	b := &strings.Builder{}
+	writeFormatArgs(b, args...)
+
+	return b.String()
+}
+
 func writeFormatArgs(w io.Writer, args ...any) {
 
 	// if first param is formatting string
@@ -266,6 +333,8 @@ func writeArg(w io.Writer, arg any) {
 			writeFormatArgs(w, val...)
 		}
 
+	case Field:
+		writeField(w, val)
 	case error:
 		w.Write(s2b(strings.TrimPrefix(val.Error(), "ERROR:")))
 	default:
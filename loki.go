@@ -0,0 +1,311 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LokiWriter sends records to push API of Grafana Loki in JSON or snappy-compressed protobuf,
+// streams of records are grouped by labels: level, static labels & values of selected fields
+type LokiWriter struct {
+	url         string
+	labels      map[string]string
+	fieldLabels []string
+	tenant      string
+	user        string
+	password    string
+	protobuf    bool
+	encoder     Encoder
+	client      *http.Client
+	retries     int
+	retryDelay  time.Duration
+	batch       *batcher
+}
+
+// LokiOption configures LokiWriter
+type LokiOption func(*LokiWriter)
+
+// LokiLabels sets static labels of all streams, ex. {"app": "api", "env": "prod"}
+func LokiLabels(labels map[string]string) LokiOption {
+	return func(w *LokiWriter) {
+		for name, value := range labels {
+			w.labels[lokiLabelName(name)] = value
+		}
+	}
+}
+
+// LokiFieldLabels sets keys of fields of records which values become labels of streams
+func LokiFieldLabels(keys ...string) LokiOption {
+	return func(w *LokiWriter) {
+		w.fieldLabels = append(w.fieldLabels, keys...)
+	}
+}
+
+// LokiTenant sets tenant of multi-tenant Loki (X-Scope-OrgID header)
+func LokiTenant(tenant string) LokiOption {
+	return func(w *LokiWriter) {
+		w.tenant = tenant
+	}
+}
+
+// LokiBasicAuth sets user & password of requests
+func LokiBasicAuth(user, password string) LokiOption {
+	return func(w *LokiWriter) {
+		w.user, w.password = user, password
+	}
+}
+
+// LokiProtobuf sends records as snappy-compressed protobuf instead of JSON
+func LokiProtobuf() LokiOption {
+	return func(w *LokiWriter) {
+		w.protobuf = true
+	}
+}
+
+// LokiEncoder sets encoder of lines of records, LogfmtEncoder by default
+func LokiEncoder(enc Encoder) LokiOption {
+	return func(w *LokiWriter) {
+		w.encoder = enc
+	}
+}
+
+// LokiClient sets http client of requests, http.DefaultClient by default
+func LokiClient(client *http.Client) LokiOption {
+	return func(w *LokiWriter) {
+		w.client = client
+	}
+}
+
+// LokiRetries sets count of repeats of failed requests & pause before first repeat, it doubles for next ones
+func LokiRetries(retries int, delay time.Duration) LokiOption {
+	return func(w *LokiWriter) {
+		w.retries, w.retryDelay = retries, delay
+	}
+}
+
+// LokiBatch sets options of batches of records
+func LokiBatch(opts BatchOptions) LokiOption {
+	return func(w *LokiWriter) {
+		w.batch = newBatcher(opts, w.send)
+	}
+}
+
+// NewLokiWriter creates writer to Loki at url (ex. 'http://localhost:3100'),
+// records are sent by batches, call Flush or Logger.Shutdown to send the rest of records
+func NewLokiWriter(url string, opts ...LokiOption) *LokiWriter {
+	w := &LokiWriter{
+		url:        strings.TrimSuffix(url, "/") + "/loki/api/v1/push",
+		labels:     make(map[string]string),
+		encoder:    LogfmtEncoder{},
+		client:     http.DefaultClient,
+		retries:    3,
+		retryDelay: time.Second,
+	}
+	w.batch = newBatcher(defaultBatchOptions, w.send)
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Write adds p as message of INFO record to batch
+func (w *LokiWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord adds rec to batch, returns error of sending of full batch or of previous batch
+func (w *LokiWriter) WriteRecord(rec *Record) error {
+	line, err := encode(w.encoder, rec)
+	if err != nil {
+		return err
+	}
+
+	return w.batch.add(rec, bytes.TrimRight(line, "\n"))
+}
+
+// Flush sends collected records
+func (w *LokiWriter) Flush(ctx context.Context) error {
+	return w.batch.flush(ctx)
+}
+
+// Dropped returns count of records of batches which weren't sent after retries
+func (w *LokiWriter) Dropped() uint64 {
+	return w.batch.dropped.Load()
+}
+
+// Close sends collected records
+func (w *LokiWriter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalTimeout)
+	defer cancel()
+
+	return w.batch.flush(ctx)
+}
+
+// lokiStream is records with the same labels
+type lokiStream struct {
+	labels map[string]string
+	items  []batchItem
+}
+
+// streams groups items by labels in order of first records of streams
+func (w *LokiWriter) streams(items []batchItem) []*lokiStream {
+	streams := make([]*lokiStream, 0)
+	byKey := make(map[string]*lokiStream)
+	for _, item := range items {
+		labels := w.recordLabels(item.rec)
+		key := lokiLabelsString(labels)
+		s, ok := byKey[key]
+		if !ok {
+			s = &lokiStream{labels: labels}
+			byKey[key] = s
+			streams = append(streams, s)
+		}
+		s.items = append(s.items, item)
+	}
+
+	return streams
+}
+
+// recordLabels returns labels of stream of rec
+func (w *LokiWriter) recordLabels(rec *Record) map[string]string {
+	labels := make(map[string]string, len(w.labels)+len(w.fieldLabels)+1)
+	for name, value := range w.labels {
+		labels[name] = value
+	}
+	labels["level"] = strings.ToLower(rec.Level.String())
+
+	for _, key := range w.fieldLabels {
+		for _, f := range rec.Fields {
+			if f.Key == key {
+				labels[lokiLabelName(key)] = fieldValue(f.Value)
+			}
+		}
+	}
+
+	return labels
+}
+
+func (w *LokiWriter) send(ctx context.Context, items []batchItem) error {
+	header := http.Header{}
+	var body []byte
+	if w.protobuf {
+		header.Set("Content-Type", "application/x-protobuf")
+		body = snappyEncode(w.encodeProtobuf(items))
+	} else {
+		header.Set("Content-Type", "application/json")
+		body = w.encodeJSON(items)
+	}
+	if w.tenant > "" {
+		header.Set("X-Scope-OrgID", w.tenant)
+	}
+	if w.user > "" {
+		header.Set("Authorization", basicAuth(w.user, w.password))
+	}
+
+	err := retry(ctx, w.retries, w.retryDelay, func() error {
+		return postRequest(ctx, w.client, w.url, header, body)
+	})
+
+	return errors.Wrap(err, "loki push")
+}
+
+// encodeJSON returns push request in JSON: {"streams":[{"stream":{labels},"values":[["ns","line"]]}]}
+func (w *LokiWriter) encodeJSON(items []batchItem) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString(`{"streams":[`)
+	for i, s := range w.streams(items) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`{"stream":`)
+		b.Write(jsonValue(s.labels))
+		b.WriteString(`,"values":[`)
+		for j, item := range s.items {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(`["`)
+			b.WriteString(strconv.FormatInt(item.rec.Time.UnixNano(), 10))
+			b.WriteString(`",`)
+			b.Write(jsonValue(string(item.data)))
+			b.WriteByte(']')
+		}
+		b.WriteString("]}")
+	}
+	b.WriteString("]}")
+
+	return b.Bytes()
+}
+
+// encodeProtobuf returns logproto.PushRequest:
+// streams = 1 {labels = 1, entries = 2 {timestamp = 1 {seconds = 1, nanos = 2}, line = 2}}
+func (w *LokiWriter) encodeProtobuf(items []batchItem) []byte {
+	req := make([]byte, 0)
+	for _, s := range w.streams(items) {
+		stream := appendProtoString(nil, 1, lokiLabelsString(s.labels))
+		for _, item := range s.items {
+			ts := appendProtoVarint(nil, 1, uint64(item.rec.Time.Unix()))
+			ts = appendProtoVarint(ts, 2, uint64(item.rec.Time.Nanosecond()))
+
+			entry := appendProtoBytes(nil, 1, ts)
+			entry = appendProtoBytes(entry, 2, item.data)
+			stream = appendProtoBytes(stream, 2, entry)
+		}
+		req = appendProtoBytes(req, 1, stream)
+	}
+
+	return req
+}
+
+// lokiLabelsString returns labels in format of Prometheus: {a="1", b="2"} sorted by names
+func lokiLabelsString(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[name]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// lokiLabelName returns name of label of letters, digits & '_' not beginning with digit
+func lokiLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,161 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// protoField is decoded field of protobuf message
+type protoField struct {
+	num   int
+	value uint64
+	data  []byte
+}
+
+// parseProto decodes fields of protobuf message with varint, fixed64 & length-delimited values
+func parseProto(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	fields := make([]protoField, 0)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		require.Positive(t, n)
+		b = b[n:]
+
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case protoVarintType:
+			f.value, n = binary.Uvarint(b)
+			b = b[n:]
+		case protoFixed64Type:
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case protoBytesType:
+			size, n := binary.Uvarint(b)
+			f.data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// lokiPush is push request decoded by fake of Loki
+type lokiPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][]string        `json:"values"`
+	} `json:"streams"`
+}
+
+func TestLokiWriterJSON(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		pushes  []lokiPush
+		headers []http.Header
+		fails   = 1
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/loki/api/v1/push", r.URL.Path)
+		lock.Lock()
+		defer lock.Unlock()
+
+		if fails > 0 {
+			fails--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var push lokiPush
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&push))
+		pushes = append(pushes, push)
+		headers = append(headers, r.Header)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w := NewLokiWriter(srv.URL, LokiLabels(map[string]string{"app": "api", "bad-name": "x"}), LokiFieldLabels("tenant"),
+		LokiTenant("team"), LokiBasicAuth("user", "pass"), LokiRetries(2, time.Millisecond))
+	l := NewLogger(WithOutput(io.Discard), WithDebug(true))
+	l.AddWriter(w)
+
+	l.StatusLog("first", F("tenant", "a"))
+	l.DebugLog("debug")
+	l.StatusLog("second", F("tenant", "a"))
+	l.ErrorLog(fakeErr{}, "failed", F("tenant", "b"))
+	require.NoError(t, l.Flush(context.Background()))
+
+	require.Len(t, pushes, 1)
+	assert.Equal(t, "team", headers[0].Get("X-Scope-OrgID"))
+	assert.True(t, strings.HasPrefix(headers[0].Get("Authorization"), "Basic "))
+
+	// records of priority lane reach writer first
+	streams := make(map[string]map[string]string)
+	values := make(map[string][][]string)
+	for _, s := range pushes[0].Streams {
+		streams[s.Stream["level"]] = s.Stream
+		values[s.Stream["level"]] = s.Values
+	}
+	require.Len(t, streams, 3)
+	assert.Equal(t, map[string]string{"app": "api", "bad_name": "x", "level": "info", "tenant": "a"}, streams["info"])
+	require.Len(t, values["info"], 2)
+	assert.Contains(t, values["info"][0][1], `msg=first tenant=a`)
+	assert.Contains(t, values["info"][1][1], `msg=second`)
+	assert.NotContains(t, values["info"][1][1], "\n")
+	assert.Len(t, values["debug"], 1)
+	assert.Equal(t, map[string]string{"app": "api", "bad_name": "x", "level": "error", "tenant": "b"}, streams["error"])
+}
+
+func TestLokiWriterProtobuf(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies <- b
+	}))
+	defer srv.Close()
+
+	w := NewLokiWriter(srv.URL, LokiProtobuf(), LokiEncoder(JSONEncoder{}), LokiBatch(BatchOptions{MaxCount: 2}))
+	ts := time.Unix(1700000000, 123)
+	require.NoError(t, w.WriteRecord(&Record{Time: ts, Level: WARNING, Message: "first"}))
+	require.NoError(t, w.WriteRecord(&Record{Time: ts, Level: WARNING, Message: "second"}))
+
+	body, err := snappyDecode(<-bodies)
+	require.NoError(t, err)
+
+	req := parseProto(t, body)
+	require.Len(t, req, 1)
+	stream := parseProto(t, req[0].data)
+	require.Len(t, stream, 3)
+	assert.Equal(t, `{level="warning"}`, string(stream[0].data))
+
+	entry := parseProto(t, stream[2].data)
+	timestamp := parseProto(t, entry[0].data)
+	assert.Equal(t, []protoField{{num: 1, value: 1700000000}, {num: 2, value: 123}}, timestamp)
+	assert.Equal(t, 2, entry[1].num)
+	assert.True(t, json.Valid(entry[1].data))
+	assert.Contains(t, string(entry[1].data), `"message":"second"`)
+}
+
+func TestLokiLabelsString(t *testing.T) {
+	assert.Equal(t, `{a="x\"y\\z\n", b="1"}`, lokiLabelsString(map[string]string{"b": "1", "a": "x\"y\\z\n"}))
+	assert.Equal(t, "_abc_d", lokiLabelName("1abc.d"))
+}
@@ -0,0 +1,380 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// digestNumbers are replaced in fingerprints, so errors differing by ids are grouped
+var digestNumbers = regexp.MustCompile(`\d+`)
+
+// MailDigestWriter accumulates ERROR & CRITICAL records during window & sends them as one email via SMTP.
+// Records are grouped by fingerprint (level, caller & message without numbers) with counts, first & last times
+type MailDigestWriter struct {
+	addr      string
+	from      string
+	to        []string
+	user      string
+	password  string
+	tlsConfig *tls.Config
+	startTLS  bool
+	service   string
+	host      string
+	level     Level
+	window    time.Duration
+	timeout   time.Duration
+	now       func() time.Time
+
+	lock   sync.Mutex
+	groups map[string]*digestGroup
+	count  int
+	start  time.Time
+	timer  *time.Timer
+	// gen is generation of timer, timer which fired after stopping doesn't send next digest
+	gen uint64
+	// err is error of sending by timer, it is returned by next WriteRecord or Flush
+	err error
+	// sendLock keeps order of digests
+	sendLock sync.Mutex
+}
+
+// digestGroup is records with the same fingerprint
+type digestGroup struct {
+	level   Level
+	message string
+	frame   StackFrame
+	count   int
+	first   time.Time
+	last    time.Time
+}
+
+// MailOption configures MailDigestWriter
+type MailOption func(*MailDigestWriter)
+
+// MailAuth sets user & password of PLAIN authentication
+func MailAuth(user, password string) MailOption {
+	return func(w *MailDigestWriter) {
+		w.user, w.password = user, password
+	}
+}
+
+// MailStartTLS requires STARTTLS with config (nil means config with host of server)
+func MailStartTLS(config *tls.Config) MailOption {
+	return func(w *MailDigestWriter) {
+		w.startTLS, w.tlsConfig = true, config
+	}
+}
+
+// MailWindow sets period of collecting records of digest, 10 minutes by default
+func MailWindow(window time.Duration) MailOption {
+	return func(w *MailDigestWriter) {
+		w.window = window
+	}
+}
+
+// MailLevel sets the least important level of records in digest, ERROR by default
+func MailLevel(level Level) MailOption {
+	return func(w *MailDigestWriter) {
+		w.level = level
+	}
+}
+
+// MailService sets name of service in subject, name of executable by default
+func MailService(name string) MailOption {
+	return func(w *MailDigestWriter) {
+		w.service = name
+	}
+}
+
+// MailTimeout sets timeout of SMTP session, 30 seconds by default
+func MailTimeout(timeout time.Duration) MailOption {
+	return func(w *MailDigestWriter) {
+		w.timeout = timeout
+	}
+}
+
+// NewMailDigestWriter creates writer sending digests from address to recipients via SMTP server at addr (host:port),
+// ex. logs.AddWriter(logs.NewMailDigestWriter("smtp.example.com:587", "logs@example.com", []string{"dev@example.com"},
+// logs.MailAuth(user, password), logs.MailStartTLS(nil)))
+func NewMailDigestWriter(addr, from string, to []string, opts ...MailOption) *MailDigestWriter {
+	w := &MailDigestWriter{
+		addr:    addr,
+		from:    from,
+		to:      to,
+		service: filepath.Base(os.Args[0]),
+		level:   ERROR,
+		window:  10 * time.Minute,
+		timeout: 30 * time.Second,
+		now:     time.Now,
+		groups:  make(map[string]*digestGroup),
+	}
+	w.host, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Write adds p as message of INFO record, it is skipped unless level of digest is INFO or less important
+func (w *MailDigestWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord adds rec to digest, returns error of sending of previous digest
+func (w *MailDigestWriter) WriteRecord(rec *Record) error {
+	if rec.Level.Severity() > w.level.Severity() {
+		return nil
+	}
+
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+	msg = strings.TrimSpace(stripColors(msg))
+	frame := alertFrame(rec)
+	t := rec.Time
+	if t.IsZero() {
+		t = w.now()
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	key := rec.Level.String() + "\x00" + frame.String() + "\x00" + digestNumbers.ReplaceAllString(msg, "#")
+	g, ok := w.groups[key]
+	if !ok {
+		g = &digestGroup{level: rec.Level, message: msg, frame: frame, first: t}
+		w.groups[key] = g
+	}
+	g.count++
+	g.last = t
+
+	if w.count == 0 {
+		w.start = w.now()
+		w.arm()
+	}
+	w.count++
+
+	err := w.err
+	w.err = nil
+
+	return err
+}
+
+// Flush sends collected digest at once
+func (w *MailDigestWriter) Flush(ctx context.Context) error {
+	err := w.send(ctx, 0)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err == nil {
+		err = w.err
+	}
+	w.err = nil
+
+	return err
+}
+
+// Close sends collected digest
+func (w *MailDigestWriter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalTimeout)
+	defer cancel()
+
+	return w.Flush(ctx)
+}
+
+// arm starts timer sending digest at the end of window
+func (w *MailDigestWriter) arm() {
+	w.gen++
+	gen := w.gen
+	w.timer = time.AfterFunc(w.window, func() {
+		w.sendByTimer(gen)
+	})
+}
+
+func (w *MailDigestWriter) sendByTimer(gen uint64) {
+	if err := w.send(context.Background(), gen); err != nil {
+		w.lock.Lock()
+		w.err = err
+		w.lock.Unlock()
+	}
+}
+
+// send takes collected groups & mails them, groups are returned to digest if mail fails.
+// Timer passes its generation gen, stale timer doesn't send, 0 sends at once
+func (w *MailDigestWriter) send(ctx context.Context, gen uint64) error {
+	w.sendLock.Lock()
+	defer w.sendLock.Unlock()
+
+	w.lock.Lock()
+	if gen > 0 && gen != w.gen {
+		w.lock.Unlock()
+		return nil
+	}
+
+	groups, count, start := w.groups, w.count, w.start
+	w.groups, w.count = make(map[string]*digestGroup), 0
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	// timer which has already fired becomes stale
+	w.gen++
+	end := w.now()
+	w.lock.Unlock()
+
+	if count == 0 {
+		return nil
+	}
+
+	digest := make([]*digestGroup, 0, len(groups))
+	for _, g := range groups {
+		digest = append(digest, g)
+	}
+	sort.Slice(digest, func(i, j int) bool {
+		if digest[i].count != digest[j].count {
+			return digest[i].count > digest[j].count
+		}
+		return digest[i].first.Before(digest[j].first)
+	})
+
+	subject := fmt.Sprintf("[%s] %d errors on %s", w.service, count, w.host)
+	body := &strings.Builder{}
+	fmt.Fprintf(body, "%d records (%d groups) of %s@%s from %s to %s\n",
+		count, len(digest), w.service, w.host, start.Format(time.DateTime), end.Format(time.DateTime))
+	for _, g := range digest {
+		fmt.Fprintf(body, "\n%d× [%s] %s\n", g.count, g.level, g.message)
+		if g.frame.File > "" {
+			fmt.Fprintf(body, "   at %s\n", g.frame)
+		}
+		fmt.Fprintf(body, "   first seen %s, last seen %s\n", g.first.Format(time.DateTime), g.last.Format(time.DateTime))
+	}
+
+	if err := w.mail(ctx, subject, body.String()); err != nil {
+		w.restore(groups, count, start)
+		return errors.Wrap(err, "mail digest")
+	}
+
+	return nil
+}
+
+// restore merges groups of unsent digest into collected ones & starts timer of next sending
+func (w *MailDigestWriter) restore(groups map[string]*digestGroup, count int, start time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for key, g := range groups {
+		if cur, ok := w.groups[key]; ok {
+			g.count += cur.count
+			g.last = cur.last
+		}
+		w.groups[key] = g
+	}
+
+	if w.count == 0 {
+		w.arm()
+	}
+	w.count += count
+	w.start = start
+}
+
+// mail sends message with subject & text body
+func (w *MailDigestWriter) mail(ctx context.Context, subject, text string) error {
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", w.from)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(w.to, ", "))
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", w.now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(msg)
+	_, _ = qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	_ = qp.Close()
+
+	dialer := &net.Dialer{Timeout: w.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", w.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(w.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	host, _, _ := net.SplitHostPort(w.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if w.startTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server doesn't support STARTTLS")
+		}
+		config := w.tlsConfig
+		if config == nil {
+			config = &tls.Config{ServerName: host}
+		}
+		if err := c.StartTLS(config); err != nil {
+			return err
+		}
+	}
+	if w.user > "" {
+		if err := c.Auth(smtp.PlainAuth("", w.user, w.password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(w.from); err != nil {
+		return err
+	}
+	for _, to := range w.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	data, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
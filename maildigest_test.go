@@ -0,0 +1,244 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"encoding/base64"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpMail is message received by fake SMTP server
+type smtpMail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// smtpServer is fake SMTP server advertising AUTH PLAIN, it passes received messages
+func smtpServer(t *testing.T) (string, <-chan smtpMail) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan smtpMail, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, mails)
+		}
+	}()
+
+	return ln.Addr().String(), mails
+}
+
+func serveSMTP(conn net.Conn, mails chan<- smtpMail) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+
+	var mail smtpMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250-localhost")
+			_ = tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			auth, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+			mail.auth = string(auth)
+			_ = tp.PrintfLine("235 Authenticated")
+		case "MAIL":
+			mail.from = strings.TrimSuffix(strings.TrimPrefix(line, "MAIL FROM:<"), ">")
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, strings.TrimSuffix(strings.TrimPrefix(line, "RCPT TO:<"), ">"))
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.data = string(data)
+			_ = tp.PrintfLine("250 OK")
+			mails <- mail
+			mail = smtpMail{}
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// mailText returns subject & decoded body of message
+func mailText(t *testing.T, data string) (string, string) {
+	t.Helper()
+	header, body, ok := strings.Cut(data, "\n\n")
+	require.True(t, ok)
+
+	subject := ""
+	for _, line := range strings.Split(header, "\n") {
+		if value, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject = value
+		}
+	}
+	text, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	require.NoError(t, err)
+
+	return subject, string(text)
+}
+
+func TestMailDigestWriter(t *testing.T) {
+	addr, mails := smtpServer(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewMailDigestWriter(addr, "logs@example.com", []string{"dev@example.com", "ops@example.com"},
+		MailAuth("user", "secret"), MailService("api"), MailWindow(time.Hour))
+	w.host = "host"
+	w.now = func() time.Time { return now }
+
+	for i, user := range []string{"17", "23", "42"} {
+		require.NoError(t, w.WriteRecord(&Record{
+			Time:    now.Add(time.Duration(i) * time.Minute),
+			Level:   ERROR,
+			Message: "user " + user + " not found",
+			File:    "users.go",
+			Line:    12,
+			Func:    "users.Get",
+		}))
+	}
+	require.NoError(t, w.WriteRecord(&Record{Time: now, Level: CRITICAL, Message: "disk is full", Err: errors.New("full")}))
+	require.NoError(t, w.WriteRecord(&Record{Time: now, Level: WARNING, Message: "not in digest"}))
+	select {
+	case <-mails:
+		t.Fatal("digest is sent before end of window")
+	default:
+	}
+
+	now = now.Add(10 * time.Minute)
+	require.NoError(t, w.Flush(t.Context()))
+
+	mail := <-mails
+	assert.Equal(t, "\x00user\x00secret", mail.auth)
+	assert.Equal(t, "logs@example.com", mail.from)
+	assert.Equal(t, []string{"dev@example.com", "ops@example.com"}, mail.to)
+
+	subject, text := mailText(t, mail.data)
+	assert.Equal(t, "[api] 4 errors on host", subject)
+	assert.Equal(t, "4 records (2 groups) of api@host from 2024-01-01 12:00:00 to 2024-01-01 12:10:00\n"+
+		"\n3× [ERROR] user 17 not found\n"+
+		"   at users.go:12 users.Get()\n"+
+		"   first seen 2024-01-01 12:00:00, last seen 2024-01-01 12:02:00\n"+
+		"\n1× [CRITICAL] disk is full\n"+
+		"   first seen 2024-01-01 12:00:00, last seen 2024-01-01 12:00:00\n", strings.ReplaceAll(text, "\r\n", "\n"))
+
+	// nothing is sent for empty digest
+	require.NoError(t, w.Close())
+	select {
+	case <-mails:
+		t.Fatal("empty digest is sent")
+	default:
+	}
+}
+
+func TestMailDigestRetry(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+
+	w := NewMailDigestWriter(ln.Addr().String(), "logs@example.com", []string{"dev@example.com"}, MailWindow(time.Hour))
+	for range 2 {
+		require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: "failed"}))
+	}
+	assert.ErrorContains(t, w.Flush(t.Context()), "mail digest")
+
+	// unsent records are kept for next digest & sending by timer is armed again
+	w.lock.Lock()
+	assert.Equal(t, 2, w.count)
+	assert.NotNil(t, w.timer)
+	w.lock.Unlock()
+
+	addr, mails := smtpServer(t)
+	w.addr = addr
+	require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: "failed"}))
+	require.NoError(t, w.WriteRecord(&Record{Level: CRITICAL, Message: "disk is full"}))
+	require.NoError(t, w.Close())
+
+	subject, text := mailText(t, (<-mails).data)
+	assert.Contains(t, subject, "4 errors")
+	assert.Contains(t, text, "3× [ERROR] failed")
+	assert.Contains(t, text, "1× [CRITICAL] disk is full")
+}
+
+func TestMailDigestStaleTimer(t *testing.T) {
+	addr, mails := smtpServer(t)
+	w := NewMailDigestWriter(addr, "logs@example.com", []string{"dev@example.com"}, MailWindow(time.Hour))
+	require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: "first"}))
+	w.lock.Lock()
+	gen := w.gen
+	w.lock.Unlock()
+
+	require.NoError(t, w.Flush(t.Context()))
+	<-mails
+	require.NoError(t, w.WriteRecord(&Record{Level: ERROR, Message: "second"}))
+
+	// timer of sent digest fires late
+	w.sendByTimer(gen)
+	select {
+	case <-mails:
+		t.Fatal("stale timer sent digest before end of window")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	w.lock.Lock()
+	assert.Equal(t, 1, w.count)
+	w.lock.Unlock()
+	require.NoError(t, w.Close())
+	_, text := mailText(t, (<-mails).data)
+	assert.Contains(t, text, "1× [ERROR] second")
+}
+
+func TestMailDigestWindow(t *testing.T) {
+	addr, mails := smtpServer(t)
+	w := NewMailDigestWriter(addr, "logs@example.com", []string{"dev@example.com"}, MailWindow(50*time.Millisecond))
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	l.ErrorLog(errors.New("failed"))
+
+	select {
+	case mail := <-mails:
+		_, text := mailText(t, mail.data)
+		assert.Contains(t, text, "1× [ERROR] failed")
+		assert.Contains(t, text, "at maildigest_test.go:")
+	case <-time.After(5 * time.Second):
+		t.Fatal("digest isn't sent after window")
+	}
+
+	tlsWriter := NewMailDigestWriter(addr, "logs@example.com", []string{"dev@example.com"}, MailStartTLS(nil))
+	require.NoError(t, tlsWriter.WriteRecord(&Record{Level: ERROR, Message: "failed"}))
+	assert.ErrorContains(t, tlsWriter.Close(), "doesn't support STARTTLS")
+}
@@ -0,0 +1,224 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// appendMsgpackArray appends header of array of n items
+func appendMsgpackArray(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+// appendMsgpackMap appends header of map of n pairs
+func appendMsgpackMap(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+func appendMsgpackBin(b []byte, v []byte) []byte {
+	switch n := len(v); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+
+	return append(b, v...)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 128:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+	}
+}
+
+// appendMsgpackEventTime appends EventTime of Fluentd: extension type 0 with seconds & nanoseconds
+func appendMsgpackEventTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xd7, 0x00)
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Unix()))
+
+	return binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+}
+
+// appendMsgpackValue appends value of field: numbers & booleans keep their types, other values are strings
+func appendMsgpackValue(b []byte, value any) []byte {
+	switch val := value.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if val {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case int:
+		return appendMsgpackInt(b, int64(val))
+	case int8:
+		return appendMsgpackInt(b, int64(val))
+	case int16:
+		return appendMsgpackInt(b, int64(val))
+	case int32:
+		return appendMsgpackInt(b, int64(val))
+	case int64:
+		return appendMsgpackInt(b, val)
+	case uint:
+		return appendMsgpackUint(b, uint64(val))
+	case uint8:
+		return appendMsgpackUint(b, uint64(val))
+	case uint16:
+		return appendMsgpackUint(b, uint64(val))
+	case uint32:
+		return appendMsgpackUint(b, uint64(val))
+	case uint64:
+		return appendMsgpackUint(b, val)
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(val))
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(val))
+	default:
+		return appendMsgpackString(b, fieldValue(value))
+	}
+}
+
+// readMsgpackStringMap reads map of string keys & string values, ex. response of Fluentd with ack
+func readMsgpackStringMap(r *bufio.Reader) (map[string]string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var n int
+	switch {
+	case c&0xf0 == 0x80:
+		n = int(c & 0x0f)
+	case c == 0xde:
+		size, err := readMsgpackSize(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		n = size
+	default:
+		return nil, errors.Errorf("msgpack map expected, got 0x%x", c)
+	}
+
+	m := make(map[string]string, n)
+	for range n {
+		key, err := readMsgpackString(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readMsgpackString(r)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+
+	return m, nil
+}
+
+func readMsgpackString(r *bufio.Reader) (string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	var n int
+	switch {
+	case c&0xe0 == 0xa0:
+		n = int(c & 0x1f)
+	case c == 0xd9 || c == 0xc4:
+		n, err = readMsgpackSize(r, 1)
+	case c == 0xda || c == 0xc5:
+		n, err = readMsgpackSize(r, 2)
+	case c == 0xdb || c == 0xc6:
+		n, err = readMsgpackSize(r, 4)
+	default:
+		return "", errors.Errorf("msgpack string expected, got 0x%x", c)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// readMsgpackSize reads big endian size of width bytes
+func readMsgpackSize(r *bufio.Reader, width int) (int, error) {
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(r, b[4-width:]); err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint32(b)), nil
+}
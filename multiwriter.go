@@ -1,10 +1,13 @@
 package logs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"sync"
+	"time"
 )
 
 var ErrBadWriter = errors.New("ErrBadWriter, it will be deleted from MultiWriter")
@@ -37,6 +40,8 @@ type WriterErr struct {
 type MultiWriter struct {
 	writers []io.Writer
 	lock    sync.RWMutex
+	// fanOut writes to writers in parallel, nil means sequential writing
+	fanOut *fanOut
 }
 
 // NewMultiWriter creates a MultiWriter
@@ -50,44 +55,174 @@ func NewMultiWriter(writers ...io.Writer) io.Writer {
 		}
 	}
 
-	return &MultiWriter{allWriters, sync.RWMutex{}}
+	return &MultiWriter{writers: allWriters}
 }
 
 func (t *MultiWriter) Write(p []byte) (int, error) {
 	if len(p) == 0 {
 		return -1, nil
 	}
-	errList := make([]WriterErr, 0) //errors.Join()
-	t.lock.RLock()
-	defer func() {
-		t.lock.RUnlock()
-		for _, item := range errList {
-			if errors.Is(item.err, ErrBadWriter) {
-				t.Remove(item.w)
-				break
-			}
+
+	return len(p), t.write(p, acceptAll, func(w io.Writer, p []byte) (int, error) {
+		return w.Write(p)
+	})
+}
+
+// WriteLevel writes p to writers accepting records of level, writers without level filter receive all records
+func (t *MultiWriter) WriteLevel(level Level, p []byte) (int, error) {
+	if len(p) == 0 {
+		return -1, nil
+	}
+
+	return len(p), t.write(p, func(w io.Writer) bool {
+		return acceptLevel(w, level)
+	}, func(w io.Writer, p []byte) (int, error) {
+		return w.Write(p)
+	})
+}
+
+// WriteRecord writes rec to writers accepting its level, RecordWriter receive record as is, others - its text
+func (t *MultiWriter) WriteRecord(rec *Record) error {
+	return t.writeRecordIf(rec, acceptAll)
+}
+
+// writeRecordIf writes rec to writers matching accept
+func (t *MultiWriter) writeRecordIf(rec *Record, accept func(io.Writer) bool) error {
+	return t.write(rec.text, func(w io.Writer) bool {
+		return accept(w) && acceptLevel(w, rec.Level)
+	}, func(w io.Writer, p []byte) (int, error) {
+		if rw, ok := w.(RecordWriter); ok {
+			return len(p), rw.WriteRecord(rec)
+		}
+
+		return w.Write(p)
+	})
+}
+
+func acceptAll(io.Writer) bool {
+	return true
+}
+
+func acceptLevel(w io.Writer, level Level) bool {
+	f, ok := w.(levelFilter)
+	return !ok || f.Accept(level)
+}
+
+// write calls writeTo with p for writers matching accept, sequentially or in parallel (see Parallel)
+func (t *MultiWriter) write(p []byte, accept func(io.Writer) bool, writeTo func(io.Writer, []byte) (int, error)) error {
+	errList := t.writeLocked(p, accept, writeTo)()
+	for _, item := range errList {
+		if errors.Is(item.err, ErrBadWriter) {
+			t.Remove(item.w)
+			break
 		}
-	}()
+	}
+
+	if len(errList) > 0 {
+		return MultiWriterErr{errList}
+	}
+
+	return nil
+}
+
+// writeLocked writes p under lock of writers, returned func gives errors of writing,
+// in parallel mode it waits results of lanes after unlocking, so slow writers don't block Remove & Parallel
+func (t *MultiWriter) writeLocked(p []byte, accept func(io.Writer) bool,
+	writeTo func(io.Writer, []byte) (int, error)) func() []WriterErr {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
 
+	if t.fanOut != nil {
+		return t.fanOut.write(t.writers, p, accept, writeTo)
+	}
+
+	errList := make([]WriterErr, 0) //errors.Join()
 	for _, w := range t.writers {
-		n, err := w.Write(p)
+		if !accept(w) {
+			continue
+		}
 
-		if err == ErrBadWriter {
-			errList = append(errList, WriterErr{err, w})
-		} else if err != nil {
-			errList = append(errList, WriterErr{err, w})
+		n, err := writeTo(w, p)
+		errList = appendWriteErrors(errList, w, n, len(p), err)
+	}
+
+	return func() []WriterErr {
+		return errList
+	}
+}
+
+// appendWriteErrors appends error of writing & short writing to errList
+func appendWriteErrors(errList []WriterErr, w io.Writer, n, size int, err error) []WriterErr {
+	if err != nil {
+		errList = append(errList, WriterErr{err, w})
+	}
+
+	if n != size {
+		errList = append(errList, WriterErr{io.ErrShortWrite, w})
+	}
+
+	return errList
+}
+
+// Parallel turns on writing to every writer by own goroutine with queue of queueSize (1024 by default):
+// writing waits writers up to timeout, the rest are reported with ErrWriteTimeout & receive next data
+// without waiting until they write pending data, data is dropped with ErrWriterBusy when queue of writer is full.
+// Zero timeout turns on sequential writing
+func (t *MultiWriter) Parallel(timeout time.Duration, queueSize int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.fanOut != nil {
+		t.fanOut.stop(func(io.Writer) bool { return true })
+		t.fanOut = nil
+	}
+
+	if timeout > 0 {
+		t.fanOut = newFanOut(timeout, queueSize)
+	}
+}
+
+// Flush flushes writers which buffer data
+func (t *MultiWriter) Flush(ctx context.Context) error {
+	return t.each(ctx, func(w io.Writer) error {
+		return flushWriter(ctx, w)
+	})
+}
+
+// Close closes writers which implement io.Closer
+func (t *MultiWriter) Close() error {
+	return t.each(context.Background(), func(w io.Writer) error {
+		return closeWriter(context.Background(), w)
+	})
+}
+
+// each calls fn for every writer, returns errors of all calls.
+// In parallel mode fn is called after pending data of writer is written, waiting is limited by ctx
+func (t *MultiWriter) each(ctx context.Context, fn func(io.Writer) error) error {
+	t.lock.RLock()
+	writers := append([]io.Writer(nil), t.writers...)
+	fanOut := t.fanOut
+	t.lock.RUnlock()
+
+	errList := make([]WriterErr, 0)
+	for _, w := range writers {
+		var err error
+		if fanOut != nil {
+			err = fanOut.call(ctx, w, fn)
+		} else {
+			err = fn(w)
 		}
 
-		if n != len(p) {
-			errList = append(errList, WriterErr{io.ErrShortWrite, w})
+		if err != nil {
+			errList = append(errList, WriterErr{err, w})
 		}
 	}
 
 	if len(errList) > 0 {
-		return len(p), MultiWriterErr{errList}
+		return MultiWriterErr{errList}
 	}
 
-	return len(p), nil
+	return nil
 }
 
 // Remove Removes all writers that are identical to the writer we need to remove
@@ -103,6 +238,30 @@ func (t *MultiWriter) Remove(writers ...io.Writer) {
 			}
 		}
 	}
+
+	if t.fanOut != nil {
+		t.fanOut.stop(func(w io.Writer) bool {
+			return slices.Contains(writers, w)
+		})
+	}
+}
+
+// removeFunc removes all writers matching fn
+func (t *MultiWriter) removeFunc(fn func(io.Writer) bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i := len(t.writers) - 1; i >= 0; i-- {
+		if fn(t.writers[i]) {
+			t.writers = append(t.writers[:i], t.writers[i+1:]...)
+		}
+	}
+
+	if t.fanOut != nil {
+		t.fanOut.stop(func(w io.Writer) bool {
+			return !slices.Contains(t.writers, w)
+		})
+	}
 }
 
 // Append Appends each writer passed as single writer entity. If multiwriter is passed, appends it as single writer.
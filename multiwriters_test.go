@@ -1,6 +1,8 @@
 package logs
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"runtime"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestErrorLogOthers(t *testing.T) {
@@ -19,7 +22,7 @@ func TestErrorLogOthers(t *testing.T) {
 		wg.Add(2)
 
 		fwriter := fakeWriter{wg}
-		mw := logErr.toOther.(*MultiWriter)
+		mw := Default().writers
 
 		SetWriters(fwriter, FgErr)
 		SetWriters(fwriter, FgErr)
@@ -94,7 +97,24 @@ func TestLogsMultiwriter(t *testing.T) {
 }
 
 func TestCustomLog(t *testing.T) {
-	CustomLog(NOTICE, "TEST", "test.go", 0, "test custom log", FgAll)
+	out := &bytes.Buffer{}
+	l := NewLogger(WithOutput(out))
+	all := make(recordWriter, 10)
+	errs := make(recordWriter, 10)
+	l.AddWriter(all)
+	l.AddWriter(errs, OnlyLevels(ERROR))
+
+	l.CustomLog(NOTICE, "TEST", "test.go", 1, "test custom log", FgAll)
+	l.CustomLog(DEBUG, "TEST", "test.go", 2, "disabled custom log", FgAll)
+	require.NoError(t, l.Flush(context.Background()))
+
+	assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte("\n")), out.String())
+	assert.Contains(t, out.String(), "[[TEST]]")
+	require.Len(t, all, 1)
+	rec := <-all
+	assert.Equal(t, NOTICE, rec.Level)
+	assert.Equal(t, "test custom log", rec.Message)
+	assert.Empty(t, errs)
 }
 
 func TestErrorsMultiwriter(t *testing.T) {
@@ -155,3 +175,135 @@ func (tw2 testWriter2) Write(b []byte) (int, error) {
 	tw2.wg.Done()
 	return len(b), nil
 }
+
+// lockedBuffer is buffer safe for concurrent use
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.String()
+}
+
+func TestParallelMultiWriter(t *testing.T) {
+	slow := gateWriter{started: make(chan string, 10), gate: make(chan struct{})}
+	fast := &lockedBuffer{}
+	mw := NewMultiWriter(slow, fast).(*MultiWriter)
+	mw.Parallel(50*time.Millisecond, 2)
+
+	_, err := mw.Write([]byte("first;"))
+	var mwErr MultiWriterErr
+	require.ErrorAs(t, err, &mwErr)
+	require.Len(t, mwErr.ErrorsList, 1)
+	assert.Equal(t, ErrWriteTimeout, mwErr.ErrorsList[0].err)
+	assert.Equal(t, slow, mwErr.ErrorsList[0].w)
+	assert.Equal(t, "first;", fast.String())
+
+	// stalled writer isn't waited, its queue holds 2 writes
+	start := time.Now()
+	for _, s := range []string{"second;", "third;"} {
+		_, err = mw.Write([]byte(s))
+		require.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	_, err = mw.Write([]byte("fourth;"))
+	require.ErrorAs(t, err, &mwErr)
+	assert.Equal(t, ErrWriterBusy, mwErr.ErrorsList[0].err)
+	assert.Equal(t, "first;second;third;fourth;", fast.String())
+
+	close(slow.gate)
+	require.NoError(t, mw.Flush(t.Context()))
+	assert.Equal(t, []string{"first;", "second;", "third;"}, []string{<-slow.started, <-slow.started, <-slow.started})
+
+	// writer is waited again after it caught up
+	_, err = mw.Write([]byte("fifth;"))
+	require.NoError(t, err)
+	assert.Equal(t, "fifth;", <-slow.started)
+
+	mw.Remove(slow)
+	assert.Empty(t, mw.fanOut.lanes[slow])
+	mw.Parallel(0, 0)
+	assert.Nil(t, mw.fanOut)
+}
+
+func TestParallelWritersFlush(t *testing.T) {
+	slow := gateWriter{started: make(chan string, 10), gate: make(chan struct{})}
+	fast := &lockedBuffer{}
+	l := NewLogger(WithOutput(io.Discard), WithParallelWriters(10*time.Millisecond, 0))
+	l.AddWriter(slow)
+	l.AddWriter(fast)
+
+	l.StatusLog("record")
+	require.Contains(t, <-slow.started, "record")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, l.Flush(ctx), context.DeadlineExceeded.Error())
+	assert.Contains(t, fast.String(), "record")
+
+	close(slow.gate)
+	require.NoError(t, l.Flush(t.Context()))
+}
+
+func TestParallelWriteRemove(t *testing.T) {
+	slow := gateWriter{started: make(chan string, 10), gate: make(chan struct{})}
+	fast := &lockedBuffer{}
+	mw := NewMultiWriter(slow, fast).(*MultiWriter)
+	mw.Parallel(time.Second, 0)
+
+	written := make(chan error)
+	go func() {
+		_, err := mw.Write([]byte("record"))
+		written <- err
+	}()
+	assert.Equal(t, "record", <-slow.started)
+
+	// waiting of slow writer doesn't block removing of writers
+	start := time.Now()
+	mw.Remove(fast)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	close(slow.gate)
+	require.NoError(t, <-written)
+	assert.Equal(t, "record", fast.String())
+}
+
+func TestParallelFlushRemove(t *testing.T) {
+	slow := gateWriter{started: make(chan string, 10), gate: make(chan struct{})}
+	mw := NewMultiWriter(slow).(*MultiWriter)
+	mw.Parallel(10*time.Millisecond, 1)
+
+	_, err := mw.Write([]byte("first"))
+	require.Error(t, err)
+	assert.Equal(t, "first", <-slow.started)
+	// queue of writer is full
+	_, err = mw.Write([]byte("second"))
+	require.NoError(t, err)
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- mw.Flush(t.Context())
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// flush waiting for place in queue doesn't block removing of writer
+	start := time.Now()
+	mw.Remove(slow)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	require.NoError(t, <-flushed)
+
+	close(slow.gate)
+	assert.Equal(t, "second", <-slow.started)
+}
@@ -0,0 +1,388 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// otlpScope is name of instrumentation scope of records
+const otlpScope = "github.com/ruslanBik4/logs"
+
+// OTLPWriter exports records to OpenTelemetry collector with OTLP/HTTP in JSON or protobuf encoding.
+// Caller of record is sent as code.* attributes, error as exception.* attributes, fields as attributes
+type OTLPWriter struct {
+	url        string
+	resource   []Field
+	header     http.Header
+	protobuf   bool
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+	batch      *batcher
+}
+
+// OTLPOption configures OTLPWriter
+type OTLPOption func(*OTLPWriter)
+
+// OTLPServiceName sets service.name of resource, name of executable by default
+func OTLPServiceName(name string) OTLPOption {
+	return func(w *OTLPWriter) {
+		w.setResource(F("service.name", name))
+	}
+}
+
+// OTLPResource sets attributes of resource, ex. F("deployment.environment", "prod"),
+// host.name is os.Hostname() by default
+func OTLPResource(attrs ...Field) OTLPOption {
+	return func(w *OTLPWriter) {
+		w.setResource(attrs...)
+	}
+}
+
+// OTLPHeaders sets additional headers of requests, ex. authorization of collector
+func OTLPHeaders(headers map[string]string) OTLPOption {
+	return func(w *OTLPWriter) {
+		for name, value := range headers {
+			w.header.Set(name, value)
+		}
+	}
+}
+
+// OTLPProtobuf sends records in protobuf encoding instead of JSON
+func OTLPProtobuf() OTLPOption {
+	return func(w *OTLPWriter) {
+		w.protobuf = true
+	}
+}
+
+// OTLPClient sets http client of requests, http.DefaultClient by default
+func OTLPClient(client *http.Client) OTLPOption {
+	return func(w *OTLPWriter) {
+		w.client = client
+	}
+}
+
+// OTLPRetries sets count of repeats of failed requests & pause before first repeat, it doubles for next ones
+func OTLPRetries(retries int, delay time.Duration) OTLPOption {
+	return func(w *OTLPWriter) {
+		w.retries, w.retryDelay = retries, delay
+	}
+}
+
+// OTLPBatch sets options of batches of records
+func OTLPBatch(opts BatchOptions) OTLPOption {
+	return func(w *OTLPWriter) {
+		w.batch = newBatcher(opts, w.send)
+	}
+}
+
+// NewOTLPWriter creates writer to collector at url (ex. 'http://localhost:4318'),
+// records are sent by batches, call Flush or Logger.Shutdown to send the rest of records
+func NewOTLPWriter(url string, opts ...OTLPOption) *OTLPWriter {
+	w := &OTLPWriter{
+		url:        strings.TrimSuffix(url, "/") + "/v1/logs",
+		header:     http.Header{},
+		client:     http.DefaultClient,
+		retries:    3,
+		retryDelay: time.Second,
+	}
+	hostname, _ := os.Hostname()
+	w.setResource(F("service.name", filepath.Base(os.Args[0])), F("host.name", hostname))
+	w.batch = newBatcher(defaultBatchOptions, w.send)
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Write adds p as message of INFO record to batch
+func (w *OTLPWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord adds rec to batch, returns error of sending of full batch or of previous batch
+func (w *OTLPWriter) WriteRecord(rec *Record) error {
+	if w.protobuf {
+		return w.batch.add(rec, w.encodeRecordProto(rec, time.Now()))
+	}
+
+	return w.batch.add(rec, w.encodeRecordJSON(rec, time.Now()))
+}
+
+// Flush sends collected records
+func (w *OTLPWriter) Flush(ctx context.Context) error {
+	return w.batch.flush(ctx)
+}
+
+// Dropped returns count of records of batches which weren't sent after retries
+func (w *OTLPWriter) Dropped() uint64 {
+	return w.batch.dropped.Load()
+}
+
+// Close sends collected records
+func (w *OTLPWriter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalTimeout)
+	defer cancel()
+
+	return w.batch.flush(ctx)
+}
+
+// setResource adds attributes of resource or replaces values of existing ones
+func (w *OTLPWriter) setResource(attrs ...Field) {
+	for _, attr := range attrs {
+		i := 0
+		for i < len(w.resource) && w.resource[i].Key != attr.Key {
+			i++
+		}
+		if i < len(w.resource) {
+			w.resource[i] = attr
+		} else {
+			w.resource = append(w.resource, attr)
+		}
+	}
+}
+
+// otlpSeverity returns SeverityNumber of OpenTelemetry for level (custom levels use severity of built-in level)
+func otlpSeverity(level Level) int {
+	switch level.Severity() {
+	case CRITICAL:
+		return 21
+	case ERROR:
+		return 17
+	case WARNING:
+		return 13
+	case NOTICE:
+		return 10
+	case INFO:
+		return 9
+	case DEBUG:
+		return 5
+	default:
+		return 1
+	}
+}
+
+// otlpBody returns message of rec without colors
+func otlpBody(rec *Record) string {
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+
+	return strings.TrimRight(stripColors(msg), "\n")
+}
+
+// otlpAttributes returns attributes of rec: caller, error with stack & fields
+func otlpAttributes(rec *Record) []Field {
+	attrs := make([]Field, 0, len(rec.Fields)+5)
+	if rec.File > "" {
+		attrs = append(attrs, F("code.filepath", rec.File), F("code.lineno", rec.Line))
+	}
+	if rec.Func > "" {
+		attrs = append(attrs, F("code.function", rec.Func))
+	}
+	if rec.Err != nil {
+		attrs = append(attrs, F("exception.message", rec.Err.Error()))
+	}
+	if len(rec.Stack) > 0 && len(rec.text) > 0 {
+		attrs = append(attrs, F("exception.stacktrace", strings.TrimSpace(stripColors(string(rec.text)))))
+	}
+
+	return append(attrs, rec.Fields...)
+}
+
+// encodeRecordJSON returns LogRecord in JSON encoding of OTLP
+func (w *OTLPWriter) encodeRecordJSON(rec *Record, observed time.Time) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString(`{"timeUnixNano":"`)
+	b.WriteString(strconv.FormatInt(recordTime(rec).UnixNano(), 10))
+	b.WriteString(`","observedTimeUnixNano":"`)
+	b.WriteString(strconv.FormatInt(observed.UnixNano(), 10))
+	b.WriteString(`","severityNumber":`)
+	b.WriteString(strconv.Itoa(otlpSeverity(rec.Level)))
+	b.WriteString(`,"severityText":`)
+	b.Write(jsonValue(rec.Level.String()))
+	b.WriteString(`,"body":`)
+	writeOTLPValueJSON(b, otlpBody(rec))
+	b.WriteString(`,"attributes":`)
+	writeOTLPAttributesJSON(b, otlpAttributes(rec))
+	b.WriteByte('}')
+
+	return b.Bytes()
+}
+
+func writeOTLPAttributesJSON(b *bytes.Buffer, attrs []Field) {
+	b.WriteByte('[')
+	for i, attr := range attrs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`{"key":`)
+		b.Write(jsonValue(attr.Key))
+		b.WriteString(`,"value":`)
+		writeOTLPValueJSON(b, attr.Value)
+		b.WriteByte('}')
+	}
+	b.WriteByte(']')
+}
+
+// writeOTLPValueJSON writes AnyValue, 64-bit integers are strings in JSON encoding of OTLP
+func writeOTLPValueJSON(b *bytes.Buffer, value any) {
+	switch val := otlpValue(value).(type) {
+	case bool:
+		b.WriteString(`{"boolValue":` + strconv.FormatBool(val) + `}`)
+	case int64:
+		b.WriteString(`{"intValue":"` + strconv.FormatInt(val, 10) + `"}`)
+	case float64:
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			b.WriteString(`{"stringValue":"` + strconv.FormatFloat(val, 'g', -1, 64) + `"}`)
+			return
+		}
+		b.WriteString(`{"doubleValue":` + strconv.FormatFloat(val, 'g', -1, 64) + `}`)
+	default:
+		b.WriteString(`{"stringValue":`)
+		b.Write(jsonValue(val))
+		b.WriteByte('}')
+	}
+}
+
+// otlpValue returns value as bool, int64, float64 or string
+func otlpValue(value any) any {
+	switch val := value.(type) {
+	case bool, int64, float64, string:
+		return val
+	case int:
+		return int64(val)
+	case int8:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case uint:
+		return int64(val)
+	case uint8:
+		return int64(val)
+	case uint16:
+		return int64(val)
+	case uint32:
+		return int64(val)
+	case uint64:
+		if val > math.MaxInt64 {
+			return strconv.FormatUint(val, 10)
+		}
+		return int64(val)
+	case float32:
+		return float64(val)
+	default:
+		return fieldValue(value)
+	}
+}
+
+// encodeRecordProto returns LogRecord in protobuf encoding
+func (w *OTLPWriter) encodeRecordProto(rec *Record, observed time.Time) []byte {
+	b := appendProtoFixed64(nil, 1, uint64(recordTime(rec).UnixNano()))
+	b = appendProtoVarint(b, 2, uint64(otlpSeverity(rec.Level)))
+	b = appendProtoString(b, 3, rec.Level.String())
+	b = appendProtoBytes(b, 5, appendOTLPValueProto(nil, otlpBody(rec)))
+	b = appendOTLPAttributesProto(b, 6, otlpAttributes(rec))
+
+	return appendProtoFixed64(b, 11, uint64(observed.UnixNano()))
+}
+
+// appendOTLPAttributesProto appends attributes as repeated KeyValue field
+func appendOTLPAttributesProto(b []byte, field int, attrs []Field) []byte {
+	for _, attr := range attrs {
+		kv := appendProtoString(nil, 1, attr.Key)
+		kv = appendProtoBytes(kv, 2, appendOTLPValueProto(nil, attr.Value))
+		b = appendProtoBytes(b, field, kv)
+	}
+
+	return b
+}
+
+// appendOTLPValueProto appends fields of AnyValue, zero values are kept as members of oneof
+func appendOTLPValueProto(b []byte, value any) []byte {
+	switch val := otlpValue(value).(type) {
+	case bool:
+		v := uint64(0)
+		if val {
+			v = 1
+		}
+		return binary.AppendUvarint(appendProtoTag(b, 2, protoVarintType), v)
+	case int64:
+		return binary.AppendUvarint(appendProtoTag(b, 3, protoVarintType), uint64(val))
+	case float64:
+		return binary.LittleEndian.AppendUint64(appendProtoTag(b, 4, protoFixed64Type), math.Float64bits(val))
+	default:
+		return appendProtoBytes(b, 1, []byte(val.(string)))
+	}
+}
+
+func (w *OTLPWriter) send(ctx context.Context, items []batchItem) error {
+	header := w.header.Clone()
+	var body []byte
+	if w.protobuf {
+		header.Set("Content-Type", "application/x-protobuf")
+		body = w.encodeProto(items)
+	} else {
+		header.Set("Content-Type", "application/json")
+		body = w.encodeJSON(items)
+	}
+
+	err := retry(ctx, w.retries, w.retryDelay, func() error {
+		return postRequest(ctx, w.client, w.url, header, body)
+	})
+
+	return errors.Wrap(err, "otlp export")
+}
+
+// encodeJSON returns ExportLogsServiceRequest with one resource & scope in JSON encoding
+func (w *OTLPWriter) encodeJSON(items []batchItem) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString(`{"resourceLogs":[{"resource":{"attributes":`)
+	writeOTLPAttributesJSON(b, w.resource)
+	b.WriteString(`},"scopeLogs":[{"scope":{"name":"` + otlpScope + `"},"logRecords":[`)
+	for i, item := range items {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(item.data)
+	}
+	b.WriteString(`]}]}]}`)
+
+	return b.Bytes()
+}
+
+// encodeProto returns ExportLogsServiceRequest with one resource & scope in protobuf encoding
+func (w *OTLPWriter) encodeProto(items []batchItem) []byte {
+	scopeLogs := appendProtoBytes(nil, 1, appendProtoString(nil, 1, otlpScope))
+	for _, item := range items {
+		scopeLogs = appendProtoBytes(scopeLogs, 2, item.data)
+	}
+
+	resourceLogs := appendProtoBytes(nil, 1, appendOTLPAttributesProto(nil, 1, w.resource))
+	resourceLogs = appendProtoBytes(resourceLogs, 2, scopeLogs)
+
+	return appendProtoBytes(nil, 1, resourceLogs)
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// otlpCollector is stand-in of collector, it fails first requests with statuses
+type otlpCollector struct {
+	*httptest.Server
+	lock     sync.Mutex
+	bodies   [][]byte
+	types    []string
+	statuses []int
+}
+
+func newOTLPCollector(t *testing.T, statuses ...int) *otlpCollector {
+	c := &otlpCollector{statuses: statuses}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		if len(c.statuses) > 0 {
+			w.WriteHeader(c.statuses[0])
+			c.statuses = c.statuses[1:]
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		c.bodies = append(c.bodies, body)
+		c.types = append(c.types, r.Header.Get("Content-Type"))
+	}))
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+// otlpAttrs returns attributes of JSON encoding as map of values
+func otlpAttrs(attrs any) map[string]any {
+	m := make(map[string]any)
+	for _, attr := range attrs.([]any) {
+		kv := attr.(map[string]any)
+		for _, v := range kv["value"].(map[string]any) {
+			m[kv["key"].(string)] = v
+		}
+	}
+
+	return m
+}
+
+func TestOTLPWriterJSON(t *testing.T) {
+	c := newOTLPCollector(t, http.StatusServiceUnavailable)
+	w := NewOTLPWriter(c.URL+"/", OTLPServiceName("api"), OTLPResource(F("host.name", "host"), F("env", "prod")),
+		OTLPHeaders(map[string]string{"Authorization": "Bearer secret"}), OTLPRetries(1, time.Millisecond),
+		OTLPBatch(BatchOptions{Interval: time.Hour}))
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	l.StatusLog("started", F("user", 7), F("ratio", 0.5), F("ok", true))
+	l.ErrorLog(errors.New("failed"), "request")
+	require.NoError(t, l.Shutdown(t.Context()))
+
+	require.Len(t, c.bodies, 1)
+	assert.Equal(t, "application/json", c.types[0])
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(c.bodies[0], &req))
+	resourceLogs := req["resourceLogs"].([]any)[0].(map[string]any)
+	assert.Equal(t, map[string]any{"service.name": "api", "host.name": "host", "env": "prod"},
+		otlpAttrs(resourceLogs["resource"].(map[string]any)["attributes"]))
+
+	scopeLogs := resourceLogs["scopeLogs"].([]any)[0].(map[string]any)
+	assert.Equal(t, otlpScope, scopeLogs["scope"].(map[string]any)["name"])
+	records := make(map[string]map[string]any)
+	for _, rec := range scopeLogs["logRecords"].([]any) {
+		records[rec.(map[string]any)["severityText"].(string)] = rec.(map[string]any)
+	}
+
+	info := records["INFO"]
+	require.NotNil(t, info)
+	assert.Equal(t, float64(9), info["severityNumber"])
+	assert.Equal(t, map[string]any{"stringValue": "started"}, info["body"])
+	assert.IsType(t, "", info["timeUnixNano"])
+	attrs := otlpAttrs(info["attributes"])
+	assert.Equal(t, "otlp_test.go", attrs["code.filepath"])
+	assert.Equal(t, "7", attrs["user"])
+	assert.Equal(t, 0.5, attrs["ratio"])
+	assert.Equal(t, true, attrs["ok"])
+
+	require.NotNil(t, records["ERROR"])
+	assert.Equal(t, float64(17), records["ERROR"]["severityNumber"])
+	assert.Equal(t, "failed", otlpAttrs(records["ERROR"]["attributes"])["exception.message"])
+}
+
+func TestOTLPWriterProtobuf(t *testing.T) {
+	c := newOTLPCollector(t)
+	w := NewOTLPWriter(c.URL, OTLPProtobuf(), OTLPServiceName("api"),
+		OTLPHeaders(map[string]string{"Authorization": "Bearer secret"}))
+
+	now := time.Unix(1700000000, 42)
+	require.NoError(t, w.WriteRecord(&Record{Time: now, Level: WARNING, Message: "slow", Fields: []Field{F("ms", 0), F("ok", false)}}))
+	require.NoError(t, w.Close())
+
+	require.Len(t, c.bodies, 1)
+	assert.Equal(t, "application/x-protobuf", c.types[0])
+
+	req := parseProto(t, c.bodies[0])
+	require.Len(t, req, 1)
+	resourceLogs := parseProto(t, req[0].data)
+	require.Len(t, resourceLogs, 2)
+
+	resource := parseProto(t, resourceLogs[0].data)
+	kv := parseProto(t, resource[0].data)
+	assert.Equal(t, "service.name", string(kv[0].data))
+	assert.Equal(t, "api", string(parseProto(t, kv[1].data)[0].data))
+
+	scopeLogs := parseProto(t, resourceLogs[1].data)
+	require.Len(t, scopeLogs, 2)
+	assert.Equal(t, otlpScope, string(parseProto(t, scopeLogs[0].data)[0].data))
+
+	rec := parseProto(t, scopeLogs[1].data)
+	require.Len(t, rec, 7)
+	assert.Equal(t, protoField{num: 1, value: uint64(now.UnixNano())}, rec[0])
+	assert.Equal(t, protoField{num: 2, value: 13}, rec[1])
+	assert.Equal(t, "WARNING", string(rec[2].data))
+	assert.Equal(t, []protoField{{num: 1, data: []byte("slow")}}, parseProto(t, rec[3].data))
+
+	ms := parseProto(t, rec[4].data)
+	assert.Equal(t, "ms", string(ms[0].data))
+	assert.Equal(t, []protoField{{num: 3}}, parseProto(t, ms[1].data))
+	ok := parseProto(t, rec[5].data)
+	assert.Equal(t, []protoField{{num: 2}}, parseProto(t, ok[1].data))
+	assert.Equal(t, 11, rec[6].num)
+
+	assert.Equal(t, math.Float64bits(1.5), parseProto(t, appendOTLPValueProto(nil, 1.5))[0].value)
+}
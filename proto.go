@@ -0,0 +1,55 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"encoding/binary"
+)
+
+// wire types of protobuf
+const (
+	protoVarintType  = 0
+	protoFixed64Type = 1
+	protoBytesType   = 2
+)
+
+// appendProtoTag appends key of field with wire type
+func appendProtoTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendProtoVarint appends field with varint value, zero value is omitted as protobuf does
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	return binary.AppendUvarint(appendProtoTag(b, field, protoVarintType), v)
+}
+
+// appendProtoFixed64 appends field with fixed64 or double value, zero value is omitted
+func appendProtoFixed64(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	return binary.LittleEndian.AppendUint64(appendProtoTag(b, field, protoFixed64Type), v)
+}
+
+// appendProtoBytes appends length-delimited field: string, bytes or embedded message
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(appendProtoTag(b, field, protoBytesType), uint64(len(v)))
+
+	return append(b, v...)
+}
+
+// appendProtoString appends string field, empty string is omitted
+func appendProtoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+
+	return appendProtoBytes(b, field, []byte(s))
+}
@@ -0,0 +1,358 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy defines what queue of records does when it is full
+type OverflowPolicy int8
+
+const (
+	// OverflowBlock waits for free place in queue
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops record which doesn't fit in queue
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest record of queue to add new one
+	OverflowDropOldest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// QueueOptions configures delivery of records to writers of Logger
+type QueueOptions struct {
+	// Size is capacity of every lane of queue of every worker
+	Size int
+	// Workers is count of goroutines which write records, every writer is served by one worker,
+	// so records of the same lane reach each writer in order of logging
+	Workers int
+	// Overflow is policy for full queue, ERROR & CRITICAL records have own lane, they go first & aren't dropped
+	Overflow OverflowPolicy
+}
+
+const defaultQueueSize = 1024
+
+var defaultQueueOptions = QueueOptions{Size: defaultQueueSize, Workers: 1, Overflow: OverflowBlock}
+
+// dispatcher delivers records to writers with bounded queues of workers
+type dispatcher struct {
+	queues  []*recordQueue
+	start   sync.Once
+	workers sync.WaitGroup
+	// write delivers rec to writers of worker shard of shards
+	write func(shard, shards int, rec *Record)
+	// notify outputs problems of delivery: dropped records & panics of writers
+	notify func(format string, args ...any)
+}
+
+func newDispatcher(opts QueueOptions, write func(shard, shards int, rec *Record), notify func(format string, args ...any)) *dispatcher {
+	if opts.Size <= 0 {
+		opts.Size = defaultQueueSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	d := &dispatcher{
+		queues: make([]*recordQueue, opts.Workers),
+		write:  write,
+		notify: notify,
+	}
+	for i := range d.queues {
+		d.queues[i] = newRecordQueue(opts.Size, opts.Overflow)
+	}
+
+	return d
+}
+
+// push adds rec to queues of all workers, workers are started on first record,
+// closed dispatcher skips records
+func (d *dispatcher) push(rec *Record) {
+	d.start.Do(func() {
+		d.workers.Add(len(d.queues))
+		for i := range d.queues {
+			go d.work(i)
+		}
+	})
+
+	for _, q := range d.queues {
+		q.push(rec)
+	}
+}
+
+// close stops accepting of records, workers deliver queued records & exit
+func (d *dispatcher) close() {
+	for _, q := range d.queues {
+		q.close()
+	}
+}
+
+func (d *dispatcher) work(shard int) {
+	defer d.workers.Done()
+
+	q := d.queues[shard]
+	for {
+		rec := q.pop()
+		if rec == nil {
+			return
+		}
+
+		d.deliver(shard, rec)
+		q.done()
+
+		if dropped := q.unreported(); dropped > 0 {
+			d.notify("queue (%s) dropped %d records", q.policy, dropped)
+		}
+	}
+}
+
+func (d *dispatcher) deliver(shard int, rec *Record) {
+	defer func() {
+		if err := recover(); err != nil {
+			d.notify("recover: %v,", err)
+		}
+	}()
+
+	d.write(shard, len(d.queues), rec)
+}
+
+// flush waits until workers deliver all records pushed before the call
+func (d *dispatcher) flush(ctx context.Context) error {
+	for _, q := range d.queues {
+		if err := q.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropped returns count of records dropped by overflow policy
+func (d *dispatcher) dropped() uint64 {
+	total := uint64(0)
+	for _, q := range d.queues {
+		total += q.dropped.Load()
+	}
+
+	return total
+}
+
+// priorityLevel is the least severe level of records of priority lane
+const priorityLevel = ERROR
+
+// lanes of recordQueue: records of priority lane are delivered first & never dropped
+const (
+	laneHigh = iota
+	laneLow
+	lanesCount
+)
+
+func laneOf(rec *Record) int {
+	if rec.Level.Severity() <= priorityLevel {
+		return laneHigh
+	}
+
+	return laneLow
+}
+
+// ring is ring buffer of records
+type ring struct {
+	buf  []*Record
+	head int
+	size int
+	// added & removed are sequences of records put to & taken from ring
+	added   uint64
+	removed uint64
+}
+
+func (r *ring) full() bool {
+	return r.size == len(r.buf)
+}
+
+func (r *ring) put(rec *Record) {
+	r.buf[(r.head+r.size)%len(r.buf)] = rec
+	r.size++
+	r.added++
+}
+
+func (r *ring) take() *Record {
+	rec := r.buf[r.head]
+	r.buf[r.head] = nil
+	r.head = (r.head + 1) % len(r.buf)
+	r.size--
+	r.removed++
+
+	return rec
+}
+
+// recordQueue is bounded FIFO of records with priority lane for ERROR & CRITICAL records
+type recordQueue struct {
+	lock     sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	handled  *sync.Cond
+	lanes    [lanesCount]ring
+	policy   OverflowPolicy
+	dropped  atomic.Uint64
+	reported uint64
+	// popLane & popSeq are lane & sequence of popped record which isn't delivered yet, popSeq is 0 without one
+	popLane int
+	popSeq  uint64
+	closed  bool
+}
+
+func newRecordQueue(size int, policy OverflowPolicy) *recordQueue {
+	q := &recordQueue{policy: policy}
+	for i := range q.lanes {
+		q.lanes[i].buf = make([]*Record, size)
+	}
+	q.notEmpty = sync.NewCond(&q.lock)
+	q.notFull = sync.NewCond(&q.lock)
+	q.handled = sync.NewCond(&q.lock)
+
+	return q
+}
+
+// push adds rec to its lane, records of priority lane wait for free place regardless of policy,
+// closed queue skips records
+func (q *recordQueue) push(rec *Record) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	lane := laneOf(rec)
+	r := &q.lanes[lane]
+	for !q.closed && r.full() {
+		switch {
+		case lane == laneHigh || q.policy == OverflowBlock:
+			q.notFull.Wait()
+		case q.policy == OverflowDropNewest:
+			q.dropped.Add(1)
+			return
+		default:
+			r.take()
+			q.dropped.Add(1)
+			q.handled.Broadcast()
+		}
+	}
+
+	if q.closed {
+		return
+	}
+
+	r.put(rec)
+	q.notEmpty.Signal()
+}
+
+// pop waits for record & removes it from queue, records of priority lane go first,
+// returns nil when queue is closed & empty
+func (q *recordQueue) pop() *Record {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for {
+		for i := range q.lanes {
+			if q.lanes[i].size > 0 {
+				rec := q.lanes[i].take()
+				q.popLane, q.popSeq = i, q.lanes[i].removed
+				// waiters of both lanes use the same condition
+				q.notFull.Broadcast()
+
+				return rec
+			}
+		}
+
+		if q.closed {
+			return nil
+		}
+		q.notEmpty.Wait()
+	}
+}
+
+// close stops accepting of records & wakes up waiting pop & push
+func (q *recordQueue) close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// done marks popped record as delivered
+func (q *recordQueue) done() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.popSeq = 0
+	q.handled.Broadcast()
+}
+
+// wait waits until all records pushed before the call are delivered or dropped,
+// every lane is waited up to its own sequence, so records of other lane pushed later don't count
+func (q *recordQueue) wait(ctx context.Context) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	stop := context.AfterFunc(ctx, func() {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+
+		q.handled.Broadcast()
+	})
+	defer stop()
+
+	var last [lanesCount]uint64
+	for i := range q.lanes {
+		last[i] = q.lanes[i].added
+	}
+
+	for !q.handledUpTo(last) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		q.handled.Wait()
+	}
+
+	return nil
+}
+
+// handledUpTo reports whether records of every lane up to sequence of last are delivered or dropped
+func (q *recordQueue) handledUpTo(last [lanesCount]uint64) bool {
+	for i := range q.lanes {
+		if q.lanes[i].removed < last[i] || q.popSeq > 0 && q.popLane == i && q.popSeq <= last[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unreported returns count of dropped records since previous call
+func (q *recordQueue) unreported() uint64 {
+	dropped := q.dropped.Load()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	n := dropped - q.reported
+	q.reported = dropped
+
+	return n
+}
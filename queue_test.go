@@ -0,0 +1,217 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueOrder(t *testing.T) {
+	const count = 200
+	writers := []recordWriter{make(recordWriter, count), make(recordWriter, count), make(recordWriter, count)}
+	l := NewLogger(WithOutput(&bytes.Buffer{}), WithQueue(QueueOptions{Size: 8, Workers: 2}))
+	for _, w := range writers {
+		l.AddWriter(w)
+	}
+
+	for i := range count {
+		l.StatusLog(fmt.Sprintf("record %d", i))
+	}
+
+	for _, w := range writers {
+		for i := range count {
+			select {
+			case rec := <-w:
+				require.Equal(t, fmt.Sprintf("record %d", i), rec.Message)
+			case <-time.After(time.Second):
+				t.Fatal("writer didn't receive record")
+			}
+		}
+	}
+	assert.Zero(t, l.Dropped())
+}
+
+func TestRecordQueueOverflow(t *testing.T) {
+	recs := make([]*Record, 5)
+	for i := range recs {
+		recs[i] = &Record{Level: INFO, Line: i}
+	}
+
+	q := newRecordQueue(3, OverflowDropNewest)
+	for _, rec := range recs {
+		q.push(rec)
+	}
+	assert.Equal(t, uint64(2), q.unreported())
+	assert.Zero(t, q.unreported())
+	assert.Equal(t, 0, q.pop().Line)
+	assert.Equal(t, 1, q.pop().Line)
+	assert.Equal(t, 2, q.pop().Line)
+
+	q = newRecordQueue(3, OverflowDropOldest)
+	for _, rec := range recs {
+		q.push(rec)
+	}
+	assert.Equal(t, uint64(2), q.dropped.Load())
+	assert.Equal(t, 2, q.pop().Line)
+	assert.Equal(t, 3, q.pop().Line)
+	assert.Equal(t, 4, q.pop().Line)
+
+	q = newRecordQueue(1, OverflowBlock)
+	q.push(recs[0])
+	pushed := make(chan struct{})
+	go func() {
+		q.push(recs[1])
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("push didn't wait for free place in queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, 0, q.pop().Line)
+	<-pushed
+	assert.Equal(t, 1, q.pop().Line)
+	assert.Zero(t, q.dropped.Load())
+}
+
+func TestRecordQueueClose(t *testing.T) {
+	q := newRecordQueue(1, OverflowBlock)
+	q.push(&Record{Level: INFO, Line: 0})
+	pushed := make(chan struct{})
+	go func() {
+		q.push(&Record{Level: INFO, Line: 1})
+		close(pushed)
+	}()
+
+	q.close()
+	<-pushed
+	q.push(&Record{Level: ERROR, Line: 2})
+	assert.Equal(t, 0, q.pop().Line)
+	q.done()
+	assert.Nil(t, q.pop())
+	require.NoError(t, q.wait(t.Context()))
+}
+
+func TestSetQueue(t *testing.T) {
+	w := make(recordWriter, 10)
+	l := NewLogger(WithOutput(&bytes.Buffer{}))
+	l.AddWriter(w)
+
+	old := l.queue
+	l.StatusLog("first")
+	l.setQueue(newDispatcher(QueueOptions{Size: 8}, l.deliver, l.notify))
+	l.StatusLog("second")
+
+	assert.Equal(t, "first", (<-w).Message)
+	assert.Equal(t, "second", (<-w).Message)
+	// records of closed queue are skipped
+	old.push(&Record{Level: INFO, Message: "skipped"})
+	require.NoError(t, old.flush(t.Context()))
+	assert.Empty(t, w)
+}
+
+func TestRecordQueuePriority(t *testing.T) {
+	q := newRecordQueue(2, OverflowDropNewest)
+	for i := range 4 {
+		q.push(&Record{Level: DEBUG, Line: i})
+	}
+	q.push(&Record{Level: ERROR, Line: 10})
+	q.push(&Record{Level: CRITICAL, Line: 11})
+
+	pushed := make(chan struct{})
+	go func() {
+		q.push(&Record{Level: ERROR, Line: 12})
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("priority record wasn't waiting for free place in queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Equal(t, 10, q.pop().Line)
+	<-pushed
+	assert.Equal(t, 11, q.pop().Line)
+	assert.Equal(t, 12, q.pop().Line)
+	assert.Equal(t, 0, q.pop().Line)
+	assert.Equal(t, 1, q.pop().Line)
+	assert.Equal(t, uint64(2), q.dropped.Load())
+}
+
+// gateWriter signals about every write & waits for gate
+type gateWriter struct {
+	started chan string
+	gate    chan struct{}
+}
+
+func (w gateWriter) Write(b []byte) (int, error) {
+	w.started <- string(b)
+	<-w.gate
+
+	return len(b), nil
+}
+
+func TestDroppedReport(t *testing.T) {
+	out := &lockedBuffer{}
+	w := gateWriter{started: make(chanWriter, 10), gate: make(chan struct{})}
+	l := NewLogger(WithOutput(&bytes.Buffer{}), WithQueue(QueueOptions{Size: 1, Overflow: OverflowDropNewest}))
+	l.AddWriter(w)
+	l.logger(WARNING).SetOutput(out)
+
+	l.StatusLog("first")
+	assert.Contains(t, chanWriter(w.started).next(t), "first")
+	for range 4 {
+		l.StatusLog("record")
+	}
+	// one record is in queue, others are dropped
+	assert.Equal(t, uint64(3), l.Dropped())
+
+	w.gate <- struct{}{}
+	assert.Contains(t, chanWriter(w.started).next(t), "record")
+	w.gate <- struct{}{}
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "queue (drop-newest) dropped 3 records")
+	}, time.Second, 10*time.Millisecond)
+}
+
+// failingWriter fails every writing
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("writer is broken")
+}
+
+func TestQueueFailingWriter(t *testing.T) {
+	out := &lockedBuffer{}
+	l := NewLogger(WithOutput(out), WithQueue(QueueOptions{Size: 2}))
+	l.AddWriter(failingWriter{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 50 {
+			l.StatusLog("record", i)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging is blocked by errors of writer")
+	}
+	assert.Eventually(t, func() bool {
+		return strings.Count(out.String(), "Write toOther") == 50
+	}, time.Second, 10*time.Millisecond)
+}
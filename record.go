@@ -0,0 +1,64 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Record is a log record passed to writers of Logger
+type Record struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	File    string
+	Line    int
+	Func    string
+	Fields  []Field
+	// Err is error of ErrorLog, CriticalLog, ErrorStack & Fatal
+	Err error
+	// Stack is call stack of ErrorStack or stack of error with stack trace
+	Stack []StackFrame
+	// text is output of record for writers without record support
+	text []byte
+}
+
+// StackFrame is a frame of call stack
+type StackFrame struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Func string `json:"func"`
+}
+
+func (f StackFrame) String() string {
+	return fmt.Sprintf("%s:%d %s()", f.File, f.Line, f.Func)
+}
+
+// Text returns record as text line which plain writers receive
+func (r *Record) Text() []byte {
+	return r.text
+}
+
+// RecordWriter is implemented by writers which handle records with structured data (level, caller, fields)
+type RecordWriter interface {
+	io.Writer
+	WriteRecord(rec *Record) error
+}
+
+// writeRecord writes rec to w as record or as text line
+func writeRecord(w io.Writer, rec *Record) error {
+	if rw, ok := w.(RecordWriter); ok {
+		return rw.WriteRecord(rec)
+	}
+
+	n, err := w.Write(rec.text)
+	if err == nil && n != len(rec.text) {
+		return io.ErrShortWrite
+	}
+
+	return err
+}
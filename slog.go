@@ -0,0 +1,180 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SlogHandler is slog.Handler which outputs records through Logger: its console, writers, Sentry & levels switches.
+// Attributes of records become fields, groups are prefixes of keys of fields ('group.key')
+type SlogHandler struct {
+	logger *Logger
+	attrs  []Field
+	prefix string
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// NewSlogHandler creates slog.Handler of default logger, ex. slog.SetDefault(slog.New(logs.NewSlogHandler()))
+func NewSlogHandler() *SlogHandler {
+	return &SlogHandler{}
+}
+
+// NewSlogHandler creates slog.Handler of l
+func (l *Logger) NewSlogHandler() *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+// SlogLevel returns Level matching level of slog
+func SlogLevel(level slog.Level) Level {
+	switch {
+	case level >= slog.LevelError+4:
+		return CRITICAL
+	case level >= slog.LevelError:
+		return ERROR
+	case level >= slog.LevelWarn:
+		return WARNING
+	case level > slog.LevelInfo:
+		return NOTICE
+	case level >= slog.LevelInfo:
+		return INFO
+	case level >= slog.LevelDebug:
+		return DEBUG
+	default:
+		return TRACE
+	}
+}
+
+func (h *SlogHandler) getLogger() *Logger {
+	if h.logger == nil {
+		return Default()
+	}
+
+	return h.logger
+}
+
+// Enabled reports whether logger outputs records of level
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.getLogger().IsEnabled(SlogLevel(level))
+}
+
+// Handle outputs r with caller of record & fields from attributes,
+// first error from attributes is error of record, it is sent to Sentry for ERROR & CRITICAL levels
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	l := h.getLogger()
+	logger := l.logger(SlogLevel(r.Level))
+	if !logger.enabled.Load() {
+		return nil
+	}
+
+	fields := make([]Field, 0, len(h.attrs)+r.NumAttrs())
+	fields = append(fields, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+
+	rec := Record{
+		Time:    r.Time,
+		Message: r.Message,
+		Fields:  fields,
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		rec.File = changeShortName(frame.File)
+		rec.Line = frame.Line
+		rec.Func = changeShortName(frame.Function)
+	}
+
+	for _, f := range fields {
+		if err, ok := f.Value.(error); ok {
+			rec.Err = err
+			break
+		}
+	}
+
+	if st, ok := rec.Err.(stackTracer); ok {
+		rec.Stack = stackTraceFrames(st.StackTrace())
+	}
+
+	if logger.level.Severity() <= ERROR {
+		err := rec.Err
+		if err == nil {
+			err = errors.New(r.Message)
+		}
+
+		if format, link, hub := l.captureSentry(err); hub != nil {
+			defer hub.Flush(2 * time.Second)
+			rec.Fields = append(rec.Fields, F("sentry", fmt.Sprintf(format, link...)))
+		}
+	}
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+
+	logger.output(0, rec, logger.header(&rec)+r.Message, true)
+
+	return nil
+}
+
+// WithAttrs returns handler which adds attrs to all records
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.attrs = make([]Field, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.prefix, a)
+	}
+
+	return &h2
+}
+
+// WithGroup returns handler which adds name as prefix of keys of following attributes
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+
+	return &h2
+}
+
+// appendAttr adds a to fields, groups are flattened with prefixes of keys
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key > "" {
+			prefix += a.Key + "."
+		}
+		for _, attr := range a.Value.Group() {
+			fields = appendAttr(fields, prefix, attr)
+		}
+
+		return fields
+	}
+
+	return append(fields, F(prefix+a.Key, a.Value.Any()))
+}
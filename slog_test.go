@@ -0,0 +1,65 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	out := &bytes.Buffer{}
+	records := make(recordWriter, 10)
+	l := NewLogger(WithOutput(out))
+	l.AddWriter(records)
+
+	logger := slog.New(l.NewSlogHandler()).With("service", "api").WithGroup("req")
+	logger.Info("handled", "id", 12, slog.Group("user", "name", "admin"))
+
+	rec := <-records
+	assert.Equal(t, INFO, rec.Level)
+	assert.Equal(t, "handled", rec.Message)
+	assert.Equal(t, "slog_test.go", rec.File)
+	assert.Equal(t, "logs.TestSlogHandler", rec.Func)
+	assert.Equal(t, []Field{{"service", "api"}, {"req.id", int64(12)}, {"req.user.name", "admin"}}, rec.Fields)
+	assert.True(t, strings.HasPrefix(out.String(), "[[INFO]]"), out.String())
+	assert.Contains(t, out.String(), "slog_test.go:")
+	assert.Contains(t, out.String(), ": handled service=api req.id=12 req.user.name=admin\n")
+
+	err := errors.New("failed")
+	slog.New(l.NewSlogHandler()).Error("request", "err", err)
+	rec = <-records
+	assert.Equal(t, ERROR, rec.Level)
+	assert.Equal(t, err, rec.Err)
+	assert.NotEmpty(t, rec.Stack)
+
+	logger.Debug("skipped")
+	assert.False(t, logger.Enabled(context.Background(), slog.LevelDebug))
+	l.SetDebug(true)
+	assert.True(t, logger.Enabled(context.Background(), slog.LevelDebug))
+	logger.Debug("debug")
+	assert.Equal(t, "debug", (<-records).Message)
+	assert.Empty(t, records)
+}
+
+func TestSlogLevel(t *testing.T) {
+	for level, want := range map[slog.Level]Level{
+		slog.LevelError + 4: CRITICAL,
+		slog.LevelError:     ERROR,
+		slog.LevelWarn:      WARNING,
+		slog.LevelInfo + 2:  NOTICE,
+		slog.LevelInfo:      INFO,
+		slog.LevelDebug:     DEBUG,
+		slog.LevelDebug - 4: TRACE,
+	} {
+		assert.Equal(t, want, SlogLevel(level), level.String())
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snappyDecode decompresses block format of snappy
+func snappyDecode(src []byte) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errors.New("bad length")
+	}
+	src = src[n:]
+
+	dst := make([]byte, 0, size)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			length := int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				bytes := length - 59
+				length = 0
+				for i := range bytes {
+					length |= int(src[i]) << (8 * i)
+				}
+				src = src[bytes:]
+			}
+			length++
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+		case 2:
+			length, offset := int(tag>>2)+1, int(binary.LittleEndian.Uint16(src[1:]))
+			if offset == 0 || offset > len(dst) {
+				return nil, errors.Errorf("bad offset %d", offset)
+			}
+			for range length {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+			src = src[3:]
+		default:
+			return nil, errors.Errorf("unexpected tag %d", tag)
+		}
+	}
+
+	if uint64(len(dst)) != size {
+		return nil, errors.Errorf("size %d != %d", len(dst), size)
+	}
+
+	return dst, nil
+}
+
+func TestSnappyEncode(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"abc",
+		strings.Repeat("a", 1000),
+		strings.Repeat("level=info msg=\"request handled\" user=42\n", 200),
+		strings.Repeat("x", 70000) + "tail",
+	} {
+		compressed := snappyEncode([]byte(src))
+		decoded, err := snappyDecode(compressed)
+		require.NoError(t, err)
+		assert.Equal(t, src, string(decoded))
+		if len(src) > 100 {
+			assert.Less(t, len(compressed), len(src)/4)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	splunkMinPoll = 100 * time.Millisecond
+	splunkMaxPoll = 2 * time.Second
+)
+
+// SplunkWriter sends records to HTTP Event Collector of Splunk as batches of events,
+// message of record is event, level, caller, error & fields of record are indexed fields
+type SplunkWriter struct {
+	url        string
+	token      string
+	host       string
+	source     string
+	sourceType string
+	index      string
+	channel    string
+	ackTimeout time.Duration
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+	batch      *batcher
+}
+
+// SplunkOption configures SplunkWriter
+type SplunkOption func(*SplunkWriter)
+
+// SplunkHost sets host of events, os.Hostname() by default
+func SplunkHost(host string) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.host = host
+	}
+}
+
+// SplunkSource sets source of events, default of token by default
+func SplunkSource(source string) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.source = source
+	}
+}
+
+// SplunkSourceType sets sourcetype of events, default of token by default
+func SplunkSourceType(sourceType string) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.sourceType = sourceType
+	}
+}
+
+// SplunkIndex sets index of events, default of token by default
+func SplunkIndex(index string) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.index = index
+	}
+}
+
+// SplunkChannel sets channel of requests (GUID), it is required by tokens with indexer acknowledgement
+func SplunkChannel(channel string) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.channel = channel
+	}
+}
+
+// SplunkAck waits acknowledgement of indexing of every batch up to timeout, batch which isn't acknowledged
+// is sent again up to count of retries (see SplunkRetries), random channel is used if SplunkChannel isn't set
+func SplunkAck(timeout time.Duration) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.ackTimeout = timeout
+	}
+}
+
+// SplunkClient sets http client of requests, http.DefaultClient by default
+func SplunkClient(client *http.Client) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.client = client
+	}
+}
+
+// SplunkRetries sets count of repeats of failed requests & pause before first repeat, it doubles for next ones
+func SplunkRetries(retries int, delay time.Duration) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.retries, w.retryDelay = retries, delay
+	}
+}
+
+// SplunkBatch sets options of batches of records
+func SplunkBatch(opts BatchOptions) SplunkOption {
+	return func(w *SplunkWriter) {
+		w.batch = newBatcher(opts, w.send)
+	}
+}
+
+// NewSplunkWriter creates writer to HEC at url (ex. 'https://splunk:8088') with token,
+// records are sent by batches, call Flush or Logger.Shutdown to send the rest of records
+func NewSplunkWriter(url, token string, opts ...SplunkOption) *SplunkWriter {
+	w := &SplunkWriter{
+		url:        strings.TrimSuffix(url, "/"),
+		token:      token,
+		client:     http.DefaultClient,
+		retries:    3,
+		retryDelay: time.Second,
+	}
+	w.host, _ = os.Hostname()
+	w.batch = newBatcher(defaultBatchOptions, w.send)
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.ackTimeout > 0 && w.channel == "" {
+		w.channel = randomGUID()
+	}
+
+	return w
+}
+
+// Write adds p as message of INFO record to batch
+func (w *SplunkWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord adds rec to batch, returns error of sending of full batch or of previous batch
+func (w *SplunkWriter) WriteRecord(rec *Record) error {
+	return w.batch.add(rec, w.event(rec))
+}
+
+// Flush sends collected records
+func (w *SplunkWriter) Flush(ctx context.Context) error {
+	return w.batch.flush(ctx)
+}
+
+// Dropped returns count of records of batches which weren't sent after retries
+func (w *SplunkWriter) Dropped() uint64 {
+	return w.batch.dropped.Load()
+}
+
+// Close sends collected records
+func (w *SplunkWriter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalTimeout)
+	defer cancel()
+
+	return w.batch.flush(ctx)
+}
+
+// event returns rec as HEC event: {"time":..,"host":..,"event":"message","fields":{..}}
+func (w *SplunkWriter) event(rec *Record) []byte {
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteString(`{"time":`)
+	b.WriteString(strconv.FormatFloat(float64(recordTime(rec).UnixMilli())/1e3, 'f', 3, 64))
+	for _, meta := range []struct{ name, value string }{
+		{"host", w.host}, {"source", w.source}, {"sourcetype", w.sourceType}, {"index", w.index},
+	} {
+		if meta.value > "" {
+			b.WriteString(`,"` + meta.name + `":`)
+			b.Write(jsonValue(meta.value))
+		}
+	}
+	b.WriteString(`,"event":`)
+	b.Write(jsonValue(strings.TrimRight(stripColors(msg), "\n")))
+
+	b.WriteString(`,"fields":{"level":`)
+	b.Write(jsonValue(rec.Level.String()))
+	fields := make([]Field, 0, len(rec.Fields)+4)
+	if rec.File > "" {
+		fields = append(fields, F("file", rec.File), F("line", rec.Line))
+	}
+	if rec.Func > "" {
+		fields = append(fields, F("func", rec.Func))
+	}
+	if rec.Err != nil {
+		fields = append(fields, F("error", rec.Err.Error()))
+	}
+	// indexed fields have string values
+	for _, f := range append(fields, rec.Fields...) {
+		b.WriteByte(',')
+		b.Write(jsonValue(f.Key))
+		b.WriteByte(':')
+		b.Write(jsonValue(fieldValue(f.Value)))
+	}
+	b.WriteString("}}\n")
+
+	return b.Bytes()
+}
+
+// hecResponse is response of HEC, ackId is returned if channel has acknowledgement
+type hecResponse struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId"`
+}
+
+func (w *SplunkWriter) header() http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "Splunk "+w.token)
+	header.Set("Content-Type", "application/json")
+	if w.channel > "" {
+		header.Set("X-Splunk-Request-Channel", w.channel)
+	}
+
+	return header
+}
+
+// send posts batch of items, with acknowledgement batch which isn't indexed in time is sent again up to retries
+func (w *SplunkWriter) send(ctx context.Context, items []batchItem) error {
+	body := &bytes.Buffer{}
+	for _, item := range items {
+		body.Write(item.data)
+	}
+
+	err := retry(ctx, w.retries, w.retryDelay, func() error {
+		var resp hecResponse
+		if err := postJSON(ctx, w.client, w.url+"/services/collector/event", w.header(), body.Bytes(), &resp); err != nil {
+			return err
+		}
+		if resp.Code != 0 {
+			return permanentError{errors.Errorf("%s (code %d)", resp.Text, resp.Code)}
+		}
+
+		if w.ackTimeout <= 0 {
+			return nil
+		}
+		if resp.AckID == nil {
+			return permanentError{errors.New("didn't return ackId, is acknowledgement enabled for token?")}
+		}
+
+		return w.waitAck(ctx, *resp.AckID)
+	})
+
+	return errors.Wrap(err, "splunk HEC")
+}
+
+// waitAck polls status of acknowledgement id until it is indexed or ackTimeout is over,
+// expiry of ackTimeout is retryable error, so batch is sent again
+func (w *SplunkWriter) waitAck(ctx context.Context, id int64) error {
+	ackCtx, cancel := context.WithTimeout(ctx, w.ackTimeout)
+	defer cancel()
+
+	ackURL := w.url + "/services/collector/ack?channel=" + url.QueryEscape(w.channel)
+	body := []byte(fmt.Sprintf(`{"acks":[%d]}`, id))
+	key := strconv.FormatInt(id, 10)
+	for delay := splunkMinPoll; ; delay = min(2*delay, splunkMaxPoll) {
+		var resp struct {
+			Acks map[string]bool `json:"acks"`
+		}
+		err := postJSON(ackCtx, w.client, ackURL, w.header(), body, &resp)
+		switch {
+		case err == nil && resp.Acks[key]:
+			return nil
+		case ackCtx.Err() != nil:
+			// request is interrupted by ackTimeout
+		case err != nil && !retryable(err):
+			return errors.Wrapf(err, "ack %d", id)
+		}
+
+		if waitRetry(ackCtx, delay) != nil {
+			if err := ctx.Err(); err != nil {
+				return errors.Wrapf(err, "ack %d", id)
+			}
+			return errors.Errorf("didn't acknowledge batch %d within %s", id, w.ackTimeout)
+		}
+	}
+}
+
+// randomGUID returns random GUID (UUID version 4)
+func randomGUID() string {
+	hi, lo := rand.Uint64(), rand.Uint64()
+	hi = hi&^0xf000 | 0x4000
+	lo = lo&^(0xc<<60) | 0x8<<60
+
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", hi>>32, hi>>16&0xffff, hi&0xffff, lo>>48, lo&0xffffffffffff)
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hecServer is stand-in of HEC, it fails first requests with statuses & acknowledges batch on second poll
+type hecServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	events   []map[string]any
+	statuses []int
+	polls    int
+	channels []string
+}
+
+func newHECServer(t *testing.T, statuses ...int) *hecServer {
+	s := &hecServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Splunk token", r.Header.Get("Authorization"))
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		channel := r.Header.Get("X-Splunk-Request-Channel")
+		switch r.URL.Path {
+		case "/services/collector/event":
+			if len(s.statuses) > 0 {
+				w.WriteHeader(s.statuses[0])
+				s.statuses = s.statuses[1:]
+				return
+			}
+
+			dec := json.NewDecoder(r.Body)
+			for {
+				var event map[string]any
+				if err := dec.Decode(&event); err == io.EOF {
+					break
+				} else if !assert.NoError(t, err) {
+					return
+				}
+				s.events = append(s.events, event)
+			}
+			s.channels = append(s.channels, channel)
+			if channel > "" {
+				_, _ = fmt.Fprint(w, `{"text":"Success","code":0,"ackId":7}`)
+			} else {
+				_, _ = fmt.Fprint(w, `{"text":"Success","code":0}`)
+			}
+
+		case "/services/collector/ack":
+			assert.Equal(t, channel, r.URL.Query().Get("channel"))
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"acks":[7]}`, string(body))
+			s.polls++
+			_, _ = fmt.Fprintf(w, `{"acks":{"7":%v}}`, s.polls > 1)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestSplunkWriter(t *testing.T) {
+	s := newHECServer(t, http.StatusServiceUnavailable)
+	w := NewSplunkWriter(s.URL+"/", "token", SplunkHost("host"), SplunkSource("api"), SplunkSourceType("_json"),
+		SplunkIndex("main"), SplunkRetries(2, time.Millisecond), SplunkBatch(BatchOptions{Interval: time.Hour}))
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	l.StatusLog("started", F("user", 7))
+	l.ErrorLog(errors.New("failed"), "request")
+	require.NoError(t, l.Shutdown(t.Context()))
+
+	require.Len(t, s.events, 2)
+	byLevel := make(map[string]map[string]any)
+	for _, event := range s.events {
+		assert.Equal(t, "host", event["host"])
+		assert.Equal(t, "api", event["source"])
+		assert.Equal(t, "_json", event["sourcetype"])
+		assert.Equal(t, "main", event["index"])
+		assert.IsType(t, float64(0), event["time"])
+		fields := event["fields"].(map[string]any)
+		byLevel[fields["level"].(string)] = event
+	}
+
+	info := byLevel["INFO"]
+	assert.Equal(t, "started", info["event"])
+	assert.Equal(t, "7", info["fields"].(map[string]any)["user"])
+	assert.Equal(t, "splunk_test.go", info["fields"].(map[string]any)["file"])
+	assert.Equal(t, "failed", byLevel["ERROR"]["fields"].(map[string]any)["error"])
+	assert.Equal(t, []string{""}, s.channels)
+}
+
+func TestSplunkAck(t *testing.T) {
+	s := newHECServer(t)
+	w := NewSplunkWriter(s.URL, "token", SplunkAck(5*time.Second), SplunkBatch(BatchOptions{Interval: time.Hour}))
+
+	_, err := w.Write([]byte("indexed"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush(t.Context()))
+
+	assert.Equal(t, 2, s.polls)
+	require.Len(t, s.channels, 1)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), s.channels[0])
+
+	// batch which isn't acknowledged in time is sent again
+	w = NewSplunkWriter(s.URL, "token", SplunkChannel("channel"), SplunkAck(50*time.Millisecond),
+		SplunkRetries(1, time.Millisecond))
+	s.polls, s.events = 0, nil
+	_, err = w.Write([]byte("resent"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Len(t, s.events, 2)
+	assert.Equal(t, s.events[0], s.events[1])
+
+	s.polls = -10
+	_, err = w.Write([]byte("lost"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, w.Close(), "splunk HEC: didn't acknowledge batch 7")
+	assert.Equal(t, -8, s.polls)
+}
+
+func TestSplunkAckTimeout(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		posts int
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		if r.URL.Path == "/services/collector/event" {
+			posts++
+			_, _ = fmt.Fprintf(w, `{"text":"Success","code":0,"ackId":%d}`, posts)
+			lock.Unlock()
+			return
+		}
+		first := posts == 1
+		lock.Unlock()
+
+		// poll of the first batch hangs longer than timeout of acknowledgement
+		if first {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"acks":{"2":true}}`)
+	}))
+	t.Cleanup(s.Close)
+
+	w := NewSplunkWriter(s.URL, "token", SplunkChannel("channel"), SplunkAck(50*time.Millisecond),
+		SplunkRetries(1, time.Millisecond))
+	_, err := w.Write([]byte("resent"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 2, posts)
+}
@@ -0,0 +1,390 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Facility is syslog facility of records
+type Facility int
+
+// facilities of RFC 5424
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFtp
+	FacilityNtp
+	FacilityAudit
+	FacilityAlert
+	FacilityClock
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// syslogSeverity returns syslog severity of level (custom levels use severity of built-in level)
+func syslogSeverity(level Level) int {
+	switch level.Severity() {
+	case CRITICAL:
+		return 2
+	case ERROR:
+		return 3
+	case WARNING:
+		return 4
+	case NOTICE:
+		return 5
+	case INFO:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// syslogTimeFormat is TIMESTAMP of RFC 5424 with microseconds
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+const (
+	syslogMinBackoff = 100 * time.Millisecond
+	syslogMaxBackoff = 30 * time.Second
+)
+
+// SyslogWriter sends records to syslog server in RFC 5424 format over udp, tcp, tls, unix or unixgram network.
+// Stream connections use octet-counting framing, lost connection is restored with exponential backoff
+type SyslogWriter struct {
+	network   string
+	addr      string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	facility Facility
+	hostname string
+	appName  string
+	procID   string
+	msgID    string
+	sdID     string
+
+	lock    sync.Mutex
+	conn    net.Conn
+	delay   time.Duration
+	retryAt time.Time
+	now     func() time.Time
+}
+
+// SyslogOption configures SyslogWriter
+type SyslogOption func(*SyslogWriter)
+
+// SyslogFacility sets facility of records, FacilityUser by default
+func SyslogFacility(facility Facility) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.facility = facility
+	}
+}
+
+// SyslogHostname sets HOSTNAME of records, os.Hostname() by default
+func SyslogHostname(hostname string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.hostname = hostname
+	}
+}
+
+// SyslogAppName sets APP-NAME of records, name of executable by default
+func SyslogAppName(name string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.appName = name
+	}
+}
+
+// SyslogProcID sets PROCID of records, pid of process by default
+func SyslogProcID(id string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.procID = id
+	}
+}
+
+// SyslogMsgID sets MSGID of records, nil value by default
+func SyslogMsgID(id string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.msgID = id
+	}
+}
+
+// SyslogSDID sets SD-ID of element with caller & fields of record, 'fields@32473' by default
+func SyslogSDID(id string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.sdID = id
+	}
+}
+
+// SyslogTLS sets config of tls network
+func SyslogTLS(config *tls.Config) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.tlsConfig = config
+	}
+}
+
+// SyslogTimeout sets timeout of connection & writing, 5 seconds by default
+func SyslogTimeout(timeout time.Duration) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.timeout = timeout
+	}
+}
+
+// NewSyslogWriter creates writer to syslog server at addr, network is one of udp, tcp, tls, unix, unixgram,
+// ex. logs.AddWriter(logs.NewSyslogWriter("udp", "localhost:514", logs.SyslogFacility(logs.FacilityLocal0)))
+// connection is established on first record
+func NewSyslogWriter(network, addr string, opts ...SyslogOption) (*SyslogWriter, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "tls", "unix", "unixgram":
+	default:
+		return nil, errors.Errorf("unsupported network of syslog '%s'", network)
+	}
+
+	w := &SyslogWriter{
+		network:  network,
+		addr:     addr,
+		timeout:  5 * time.Second,
+		facility: FacilityUser,
+		appName:  filepath.Base(os.Args[0]),
+		procID:   strconv.Itoa(os.Getpid()),
+		sdID:     "fields@32473",
+		now:      time.Now,
+	}
+	w.hostname, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w, nil
+}
+
+// Write sends p as message of INFO record
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	err := w.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: string(p)})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteRecord sends rec to syslog server
+func (w *SyslogWriter) WriteRecord(rec *Record) error {
+	msg := w.format(rec)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.send(msg)
+}
+
+// Close closes connection to server
+func (w *SyslogWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return errors.Wrap(err, "close syslog connection")
+}
+
+// format returns rec as SYSLOG-MSG of RFC 5424
+func (w *SyslogWriter) format(rec *Record) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("<")
+	b.WriteString(strconv.Itoa(int(w.facility)*8 + syslogSeverity(rec.Level)))
+	b.WriteString(">1 ")
+
+	t := rec.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	b.WriteString(t.Format(syslogTimeFormat))
+	for _, s := range []struct {
+		value string
+		size  int
+	}{{w.hostname, 255}, {w.appName, 48}, {w.procID, 128}, {w.msgID, 32}} {
+		b.WriteByte(' ')
+		b.WriteString(syslogHeaderValue(s.value, s.size))
+	}
+
+	b.WriteByte(' ')
+	w.writeStructuredData(b, rec)
+
+	msg := rec.Message
+	if msg == "" {
+		msg = string(rec.text)
+	}
+	if msg = strings.TrimRight(stripColors(msg), "\n"); msg > "" {
+		b.WriteByte(' ')
+		b.WriteString(msg)
+	}
+
+	return b.Bytes()
+}
+
+// writeStructuredData writes SD-ELEMENT with caller, error & fields of rec or NILVALUE
+func (w *SyslogWriter) writeStructuredData(b *bytes.Buffer, rec *Record) {
+	params := make([]Field, 0, len(rec.Fields)+4)
+	if rec.File > "" {
+		params = append(params, F("file", rec.File), F("line", rec.Line))
+	}
+	if rec.Func > "" {
+		params = append(params, F("func", rec.Func))
+	}
+	if rec.Err != nil {
+		params = append(params, F("error", rec.Err.Error()))
+	}
+	params = append(params, rec.Fields...)
+
+	if len(params) == 0 {
+		b.WriteByte('-')
+		return
+	}
+
+	b.WriteByte('[')
+	b.WriteString(syslogName(w.sdID))
+	for _, p := range params {
+		b.WriteByte(' ')
+		b.WriteString(syslogName(p.Key))
+		b.WriteString(`="`)
+		for _, r := range fieldValue(p.Value) {
+			if r == '"' || r == '\\' || r == ']' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte(']')
+}
+
+// syslogHeaderValue returns value of header field of printable ASCII limited by size or NILVALUE
+func syslogHeaderValue(value string, size int) string {
+	if value == "" {
+		return "-"
+	}
+
+	b := []byte(value)
+	if len(b) > size {
+		b = b[:size]
+	}
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
+
+// syslogName returns SD-NAME: up to 32 printable ASCII chars except '=', ' ', ']' & '"'
+func syslogName(name string) string {
+	b := []byte(syslogHeaderValue(name, 32))
+	for i, c := range b {
+		if c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
+
+// send writes msg to connection, connection is restored once if it was broken
+func (w *SyslogWriter) send(msg []byte) error {
+	if w.isStream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	var err error
+	for range 2 {
+		if w.conn == nil {
+			if err = w.connect(); err != nil {
+				return err
+			}
+		}
+
+		if w.timeout > 0 {
+			_ = w.conn.SetWriteDeadline(w.now().Add(w.timeout))
+		}
+		if _, err = w.conn.Write(msg); err == nil {
+			return nil
+		}
+
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	return errors.Wrap(err, "write to syslog")
+}
+
+func (w *SyslogWriter) isStream() bool {
+	switch w.network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return false
+	default:
+		return true
+	}
+}
+
+// connect dials server, failed attempts are repeated after growing delay
+func (w *SyslogWriter) connect() error {
+	if now := w.now(); now.Before(w.retryAt) {
+		return errors.Errorf("syslog %s is unavailable, reconnect in %s", w.addr, w.retryAt.Sub(now).Round(time.Millisecond))
+	}
+
+	dialer := &net.Dialer{Timeout: w.timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if w.network == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.addr, w.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(w.network, w.addr)
+	}
+
+	if err != nil {
+		w.delay = min(max(2*w.delay, syslogMinBackoff), syslogMaxBackoff)
+		w.retryAt = w.now().Add(w.delay)
+
+		return errors.Wrap(err, "connect to syslog")
+	}
+
+	w.conn = conn
+	w.delay = 0
+	w.retryAt = time.Time{}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2018 Author: Ruslan Bikchentaev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syslogTestTime = time.Date(2024, 5, 6, 10, 11, 12, 345678000, time.UTC)
+
+func TestSyslogFormat(t *testing.T) {
+	w, err := NewSyslogWriter("udp", "localhost:514", SyslogFacility(FacilityLocal0), SyslogHostname("host"),
+		SyslogAppName("app name"), SyslogProcID("42"), SyslogMsgID("audit"))
+	require.NoError(t, err)
+
+	rec := &Record{
+		Time:    syslogTestTime,
+		Level:   ERROR,
+		Message: "request failed\n",
+		File:    "main.go",
+		Line:    10,
+		Func:    "main.run",
+		Err:     errors.New(`bad "input"`),
+		Fields:  []Field{F("user id", 7), F("path", "/a]b")},
+	}
+	assert.Equal(t, `<131>1 2024-05-06T10:11:12.345678Z host app_name 42 audit `+
+		`[fields@32473 file="main.go" line="10" func="main.run" error="bad \"input\"" user_id="7" path="/a\]b"] request failed`,
+		string(w.format(rec)))
+
+	w, err = NewSyslogWriter("tcp", "localhost:514", SyslogHostname(""), SyslogAppName(""), SyslogProcID(""))
+	require.NoError(t, err)
+	assert.Equal(t, "<15>1 2024-05-06T10:11:12.345678Z - - - - - debug",
+		string(w.format(&Record{Time: syslogTestTime, Level: TRACE, Message: "debug"})))
+
+	_, err = NewSyslogWriter("http", "localhost:514")
+	assert.Error(t, err)
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w, err := NewSyslogWriter("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer w.Close()
+
+	l := NewLogger(WithOutput(io.Discard))
+	l.AddWriter(w)
+	l.WarningLog("disk is full", F("free", 0))
+
+	buf := make([]byte, 2048)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "<12>1 "), msg)
+	assert.Contains(t, msg, ` file="syslog_test.go" line="`)
+	assert.True(t, strings.HasSuffix(msg, ` free="0"] disk is full`), msg)
+}
+
+// readFrame reads syslog message with octet-counting framing
+func readFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	size, err := r.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(size))
+	require.NoError(t, err)
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	require.NoError(t, err)
+
+	return string(b)
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	frames := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for range 2 {
+			frames <- readFrame(t, r)
+		}
+	}()
+
+	w, err := NewSyslogWriter("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, w.WriteRecord(&Record{Time: syslogTestTime, Level: INFO, Message: "first\nline"}))
+	_, err = w.Write([]byte("second"))
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasSuffix(<-frames, " - first\nline"))
+	assert.True(t, strings.HasSuffix(<-frames, " - second"))
+}
+
+func TestSyslogUnixgram(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenPacket("unixgram", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w, err := NewSyslogWriter("unixgram", addr, SyslogFacility(FacilityDaemon))
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, w.WriteRecord(&Record{Level: CRITICAL, Message: "critical"}))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(buf[:n], []byte("<26>1 ")))
+}
+
+func TestSyslogReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	clock := time.Now()
+	w, err := NewSyslogWriter("tcp", addr)
+	require.NoError(t, err)
+	w.now = func() time.Time { return clock }
+
+	rec := &Record{Level: INFO, Message: "record"}
+	assert.ErrorContains(t, w.WriteRecord(rec), "connect to syslog")
+	assert.ErrorContains(t, w.WriteRecord(rec), "reconnect in 100ms")
+
+	clock = clock.Add(syslogMinBackoff)
+	assert.ErrorContains(t, w.WriteRecord(rec), "connect to syslog")
+	assert.Equal(t, 2*syslogMinBackoff, w.delay)
+
+	ln, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	clock = clock.Add(2 * syslogMinBackoff)
+	require.NoError(t, w.WriteRecord(rec))
+	assert.Zero(t, w.delay)
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.True(t, strings.HasSuffix(readFrame(t, bufio.NewReader(conn)), " - record"))
+	require.NoError(t, w.Close())
+}
+
+// selfSignedCert returns certificate of 127.0.0.1 & pool of roots trusting it
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "syslog test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestSyslogTLS(t *testing.T) {
+	cert, roots := selfSignedCert(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer ln.Close()
+
+	frames := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				// client without trusted roots rejects certificate of server
+				if conn.(*tls.Conn).Handshake() == nil {
+					frames <- readFrame(t, bufio.NewReader(conn))
+				}
+			}()
+		}
+	}()
+
+	untrusted, err := NewSyslogWriter("tls", ln.Addr().String())
+	require.NoError(t, err)
+	assert.ErrorContains(t, untrusted.WriteRecord(&Record{Level: INFO, Message: "untrusted"}), "certificate")
+
+	w, err := NewSyslogWriter("tls", ln.Addr().String(), SyslogTLS(&tls.Config{RootCAs: roots}))
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, w.WriteRecord(&Record{Time: syslogTestTime, Level: ERROR, Message: "over tls"}))
+	select {
+	case frame := <-frames:
+		assert.True(t, strings.HasPrefix(frame, "<11>1 "), frame)
+		assert.True(t, strings.HasSuffix(frame, " - over tls"), frame)
+	case <-time.After(time.Second):
+		t.Fatal("syslog server didn't receive message over tls")
+	}
+}
+
+func TestSyslogUnixStream(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log.sock")
+	ln, err := net.Listen("unix", addr)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+
+	w, err := NewSyslogWriter("unix", addr)
+	require.NoError(t, err)
+
+	first := &Record{Time: syslogTestTime, Level: INFO, Message: "first\nline"}
+	second := &Record{Time: syslogTestTime, Level: WARNING, Message: "second"}
+	require.NoError(t, w.WriteRecord(first))
+	require.NoError(t, w.WriteRecord(second))
+	require.NoError(t, w.Close())
+
+	want := &bytes.Buffer{}
+	for _, rec := range []*Record{first, second} {
+		msg := w.format(rec)
+		want.WriteString(strconv.Itoa(len(msg)) + " ")
+		want.Write(msg)
+	}
+	assert.Equal(t, want.String(), string(<-received))
+}
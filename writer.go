@@ -5,14 +5,15 @@
 package logs
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/getsentry/sentry-go"
 	"github.com/pkg/errors"
 )
 
@@ -57,20 +58,42 @@ type errLogPrint bool
 
 // Fatal - output formated (function and line calls) fatal information
 func Fatal(err error, args ...any) {
+	Default().fatal(err, args...)
+}
+
+// Fatal - output formated (function and line calls) fatal information
+func (l *Logger) Fatal(err error, args ...any) {
+	l.fatal(err, args...)
+}
+
+func (l *Logger) fatal(err error, args ...any) {
 	pc, _, _, _ := runtime.Caller(2)
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	logErr.Printf(
-		errLogPrint(true),
-		"%s[[FATAL]]%s%s%s %v %v",
-		boldcolors[CRITICAL],
-		LogEndColor,
-		timeLogFormat(),
-		changeShortName(runtime.FuncForPC(pc).Name()),
-		err,
-		args,
+	funcName := changeShortName(runtime.FuncForPC(pc).Name())
+	logErr := l.logger(ERROR)
+	logErr.output(0,
+		Record{
+			Message: fmt.Sprintf("FATAL %v %v", err, args),
+			Func:    funcName,
+			Err:     err,
+		},
+		fmt.Sprintf("%s[[FATAL]]%s%s%s %v %v",
+			levelBoldColor(CRITICAL),
+			LogEndColor,
+			logErr.timeLogFormat(),
+			funcName,
+			err,
+			args,
+		),
+		true,
 	)
-	ErrorStack(err, args...)
+	l.errorStack(err, args...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), fatalTimeout)
+	defer cancel()
+
+	_ = l.Shutdown(ctx)
 	os.Exit(1)
 }
 
@@ -87,21 +110,122 @@ func changeShortName(file string) (short string) {
 
 // DebugLog output formatted (function and line calls) debug information
 func DebugLog(args ...any) {
-	if *fDebug {
-		logDebug.lock.Lock()
-		defer logDebug.lock.Unlock()
+	Default().levelLog(DEBUG, args...)
+}
 
-		pc, _, _, _ := runtime.Caller(logDebug.callDepth - 2)
-		logDebug.funcName = changeShortName(runtime.FuncForPC(pc).Name())
+// DebugLog output formatted (function and line calls) debug information
+func (l *Logger) DebugLog(args ...any) {
+	l.levelLog(DEBUG, args...)
+}
 
-		logDebug.Printf(args...)
-	}
+// TraceLog output formatted (function and line calls) trace information, it is more verbose than debug
+func TraceLog(args ...any) {
+	Default().levelLog(TRACE, args...)
+}
+
+// TraceLog output formatted (function and line calls) trace information, it is more verbose than debug
+func (l *Logger) TraceLog(args ...any) {
+	l.levelLog(TRACE, args...)
 }
 
 // StatusLog output formatted information for status
 func StatusLog(args ...any) {
-	if *fStatus {
-		logStat.Printf(args...)
+	Default().levelLog(INFO, args...)
+}
+
+// StatusLog output formatted information for status
+func (l *Logger) StatusLog(args ...any) {
+	l.levelLog(INFO, args...)
+}
+
+// NoticeLog output formatted information for normal but significant events
+func NoticeLog(args ...any) {
+	Default().levelLog(NOTICE, args...)
+}
+
+// NoticeLog output formatted information for normal but significant events
+func (l *Logger) NoticeLog(args ...any) {
+	l.levelLog(NOTICE, args...)
+}
+
+// WarningLog output formatted information for warnings
+func WarningLog(args ...any) {
+	Default().levelLog(WARNING, args...)
+}
+
+// WarningLog output formatted information for warnings
+func (l *Logger) WarningLog(args ...any) {
+	l.levelLog(WARNING, args...)
+}
+
+// Log output formatted (function and line calls) information of level, it is useful for custom levels
+func Log(level Level, args ...any) {
+	Default().levelLog(level, args...)
+}
+
+// Log output formatted (function and line calls) information of level, it is useful for custom levels
+func (l *Logger) Log(level Level, args ...any) {
+	l.levelLog(level, args...)
+}
+
+// ErrorLog - output formatted (function and line calls) error information with fields of e
+func (e *Entry) ErrorLog(err error, args ...any) {
+	e.logger.errorLog(ERROR, err, e.args(args)...)
+}
+
+// CriticalLog - output formatted (function and line calls) information of critical error with fields of e
+func (e *Entry) CriticalLog(err error, args ...any) {
+	e.logger.errorLog(CRITICAL, err, e.args(args)...)
+}
+
+// WarningLog output formatted information for warnings with fields of e
+func (e *Entry) WarningLog(args ...any) {
+	e.logger.levelLog(WARNING, e.args(args)...)
+}
+
+// NoticeLog output formatted information for normal but significant events with fields of e
+func (e *Entry) NoticeLog(args ...any) {
+	e.logger.levelLog(NOTICE, e.args(args)...)
+}
+
+// StatusLog output formatted information for status with fields of e
+func (e *Entry) StatusLog(args ...any) {
+	e.logger.levelLog(INFO, e.args(args)...)
+}
+
+// DebugLog output formatted (function and line calls) debug information with fields of e
+func (e *Entry) DebugLog(args ...any) {
+	e.logger.levelLog(DEBUG, e.args(args)...)
+}
+
+// TraceLog output formatted (function and line calls) trace information with fields of e
+func (e *Entry) TraceLog(args ...any) {
+	e.logger.levelLog(TRACE, e.args(args)...)
+}
+
+// Log output formatted (function and line calls) information of level with fields of e
+func (e *Entry) Log(level Level, args ...any) {
+	e.logger.levelLog(level, e.args(args)...)
+}
+
+func (l *Logger) levelLog(level Level, args ...any) {
+	if _, ok := level.info(); !ok {
+		args = []any{errors.Errorf("unknown level %d", level), args}
+		level = ERROR
+	}
+
+	logger := l.logger(level)
+	if logger.enabled.Load() {
+		logger.lock.Lock()
+		defer logger.lock.Unlock()
+
+		logger.callDepth = levelCallDepth
+		pc, file, line, _ := runtime.Caller(logger.callDepth - 2)
+		logger.funcName = changeShortName(runtime.FuncForPC(pc).Name())
+		logger.fileName = changeShortName(file)
+		logger.line = line
+
+		logger.Printf(args...)
 	}
 }
 
@@ -109,9 +233,8 @@ type stackTracer interface {
 	StackTrace() errors.StackTrace
 }
 
-func timeLogFormat() string {
-	// todo get flags of current log !
-	if logErr.Flags()&log.Ltime != 0 {
+func (logger *wrapKitLogger) timeLogFormat() string {
+	if logger.Flags()&log.Ltime != 0 {
 		hh, mm, ss := time.Now().Clock()
 		return fmt.Sprintf("%.2d:%.2d:%.2d ", hh, mm, ss)
 	}
@@ -121,13 +244,34 @@ func timeLogFormat() string {
 
 // ErrorLog - output formatted (function and line calls) error information
 func ErrorLog(err error, args ...any) {
+	Default().errorLog(ERROR, err, args...)
+}
+
+// ErrorLog - output formatted (function and line calls) error information
+func (l *Logger) ErrorLog(err error, args ...any) {
+	l.errorLog(ERROR, err, args...)
+}
+
+// CriticalLog - output formatted (function and line calls) information of critical error
+func CriticalLog(err error, args ...any) {
+	Default().errorLog(CRITICAL, err, args...)
+}
+
+// CriticalLog - output formatted (function and line calls) information of critical error
+func (l *Logger) CriticalLog(err error, args ...any) {
+	l.errorLog(CRITICAL, err, args...)
+}
+
+func (l *Logger) errorLog(level Level, err error, args ...any) {
+	logErr := l.logger(level)
 	logErr.lock.Lock()
 	defer logErr.lock.Unlock()
 
-	if err == nil {
+	if err == nil || !logErr.enabled.Load() {
 		return
 	}
 
+	args, fields := splitFields(args)
 	b := &strings.Builder{}
 	format, c := getFormatString(args)
 	if c > 0 {
@@ -143,67 +287,79 @@ func ErrorLog(err error, args ...any) {
 		args = args[:0]
 	}
 
-	if logErr.toSentry {
-		defer sentry.Flush(2 * time.Second)
-		args = append(args, logErr.sentryOrg, string(*(sentry.CaptureException(errors.Wrap(err, "sentry")))))
-		if logErr.sentryDsn > "" {
-			b.WriteString(" " + logErr.sentryDsn + "/%s/?query=%s")
-		} else {
-			b.WriteString(" https://sentry.io/organizations/%s/?query=%s")
-		}
+	if format, link, hub := l.captureSentry(err); hub != nil {
+		defer hub.Flush(2 * time.Second)
+		args = append(args, link...)
+		b.WriteString(" " + format)
 	}
 
-	ErrFmt, ok := err.(stackTracer)
-	if ok {
-		errorPrint := errLogPrint(true)
-		frames := ErrFmt.StackTrace()
-		for _, frame := range frames {
-			file := fmt.Sprintf("%s", frame)
-			fncName := fmt.Sprintf("%n", frame)
-			if !isIgnoreFile(file) && !isIgnoreFunc(fncName) {
-
-				args = append([]any{
-					errorPrint,
-					logErr.Prefix() + "%s%s:%d: %s() " + b.String(),
-					timeLogFormat(),
-					file,
-					frame,
-					fncName,
-				},
-					args...)
-
-				break
-			}
-		}
-	} else {
+	var stack []StackFrame
+	if ErrFmt, ok := err.(stackTracer); ok {
+		stack = stackTraceFrames(ErrFmt.StackTrace())
+	}
 
-		callDepth := 1
-		isIgnore := true
+	if len(stack) > 0 {
+		frame := stack[0]
+		msg := formatArgs(append([]any{b.String()}, args...)...)
+		logErr.output(0,
+			Record{
+				Message: msg,
+				File:    frame.File,
+				Line:    frame.Line,
+				Func:    frame.Func,
+				Fields:  fields,
+				Err:     err,
+				Stack:   stack,
+			},
+			fmt.Sprintf("%s%s%s:%d: %s() %s", logErr.Prefix(), logErr.timeLogFormat(), frame.File, frame.Line, frame.Func, msg),
+			true,
+		)
 
-		for pc, file, line, ok := runtime.Caller(callDepth); ok && isIgnore; pc, file, line, ok = runtime.Caller(callDepth) {
-			logErr.fileName = changeShortName(file)
-			logErr.funcName = changeShortName(runtime.FuncForPC(pc).Name())
-			logErr.line = line
-			// пропускаем рендер ошибок
-			isIgnore = isIgnoreFile(logErr.fileName) || isIgnoreFunc(logErr.funcName)
-			callDepth++
-		}
+		return
+	}
 
-		logErr.callDepth = callDepth + 1
+	callDepth := 1
+	isIgnore := true
 
-		args = append([]any{
-			logErr.funcName + "() " + b.String(),
-		},
-			args...)
+	for pc, file, line, ok := runtime.Caller(callDepth); ok && isIgnore; pc, file, line, ok = runtime.Caller(callDepth) {
+		logErr.fileName = changeShortName(file)
+		logErr.funcName = changeShortName(runtime.FuncForPC(pc).Name())
+		logErr.line = line
+		// пропускаем рендер ошибок
+		isIgnore = isIgnoreFile(logErr.fileName) || isIgnoreFunc(logErr.funcName)
+		callDepth++
 	}
 
-	logErr.Printf(args...)
+	logErr.callDepth = callDepth + 1
+
+	msg := formatArgs(append([]any{b.String()}, args...)...)
+	logErr.output(logErr.callDepth,
+		Record{
+			Message: msg,
+			File:    logErr.fileName,
+			Line:    logErr.line,
+			Func:    logErr.funcName,
+			Fields:  fields,
+			Err:     err,
+		},
+		logErr.funcName+"() "+msg,
+		false,
+	)
 }
 
 const prefErrStack = "[[ERR_STACK]]"
 
 // ErrorStack - output formatted (function and line calls) error runtime stack information
 func ErrorStack(err error, args ...any) {
+	Default().errorStack(err, args...)
+}
+
+// ErrorStack - output formatted (function and line calls) error runtime stack information
+func (l *Logger) ErrorStack(err error, args ...any) {
+	l.errorStack(err, args...)
+}
+
+func (l *Logger) errorStack(err error, args ...any) {
 
 	b := &strings.Builder{}
 
@@ -223,37 +379,75 @@ func ErrorStack(err error, args ...any) {
 		args = args[:0]
 	}
 
-	b.WriteString("\n")
+	msg := b.String()
 
-	ErrFmt, ok := err.(stackTracer)
-	if ok {
-		frames := ErrFmt.StackTrace()
-		for _, frame := range frames[:len(frames)-2] {
-			fileName := fmt.Sprintf("%s", frame)
-			fncName := fmt.Sprintf("%n", frame)
-			if !isIgnoreFile(fileName) && !isIgnoreFunc(fncName) {
-				fmt.Fprintf(b, "%s:%d %s %s()\n", fileName, frame, prefErrStack, fncName)
-			}
-		}
+	var frames []StackFrame
+	if ErrFmt, ok := err.(stackTracer); ok {
+		stack := ErrFmt.StackTrace()
+		frames = stackTraceFrames(stack[:len(stack)-2])
 	} else {
-		WriteStack(b, stackBeginWith)
+		frames = callerFrames(int(l.stackBeginWith.Load()))
+	}
+
+	b.WriteString("\n")
+	for _, frame := range frames {
+		fmt.Fprintf(b, "%s:%d %s %s()\n", frame.File, frame.Line, prefErrStack, frame.Func)
 	}
 
+	rec := Record{
+		Message: strings.TrimPrefix(msg, prefErrStack),
+		Err:     err,
+		Stack:   frames,
+	}
+	if len(frames) > 0 {
+		rec.File, rec.Line, rec.Func = frames[0].File, frames[0].Line, frames[0].Func
+	}
+
+	logErr := l.logger(ERROR)
 	logErr.lock.Lock()
-	logErr.Printf(errLogPrint(true), b.String())
+	logErr.output(0, rec, b.String(), true)
 	logErr.lock.Unlock()
 }
 
 func WriteStack(b *strings.Builder, i int) {
-	for pc, file, line, ok := runtime.Caller(i); ok; pc, file, line, ok = runtime.Caller(i) {
-		i++
+	for _, frame := range callerFrames(i) {
+		fmt.Fprintf(b, "%s:%d %s %s()\n", frame.File, frame.Line, prefErrStack, frame.Func)
+	}
+}
+
+// callerFrames returns not ignored frames of runtime stack, skip = 0 means caller of callerFrames
+func callerFrames(skip int) []StackFrame {
+	frames := make([]StackFrame, 0)
+	for i := skip + 1; ; i++ {
+		pc, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		}
+
 		fileName := changeShortName(file)
 		fncName := changeShortName(runtime.FuncForPC(pc).Name())
 		// skip errors rendering
 		if !isIgnoreFile(fileName) && !isIgnoreFunc(fncName) {
-			fmt.Fprintf(b, "%s:%d %s %s()\n", fileName, line, prefErrStack, fncName)
+			frames = append(frames, StackFrame{File: fileName, Line: line, Func: fncName})
 		}
 	}
+
+	return frames
+}
+
+// stackTraceFrames returns not ignored frames of stack trace of error
+func stackTraceFrames(stack errors.StackTrace) []StackFrame {
+	frames := make([]StackFrame, 0, len(stack))
+	for _, frame := range stack {
+		fileName := fmt.Sprintf("%s", frame)
+		fncName := fmt.Sprintf("%n", frame)
+		if !isIgnoreFile(fileName) && !isIgnoreFunc(fncName) {
+			line, _ := strconv.Atoi(fmt.Sprintf("%d", frame))
+			frames = append(frames, StackFrame{File: fileName, Line: line, Func: fncName})
+		}
+	}
+
+	return frames
 }
 
 func isIgnoreFile(runFile string) bool {
@@ -280,32 +474,41 @@ func ErrorLogHandler(err error, args ...any) {
 	ErrorStack(err, args...)
 }
 
+// CustomLog output msg with prefix & color of level once & routes its record to writers accepting level
 func CustomLog(level Level, prefix, fileName string, line int, msg string, logFlags ...FgLogWriter) {
-	args := []any{
-		errLogPrint(true),
-		"%s[[%s]]%s%s%s:%d: %s",
+	Default().CustomLog(level, prefix, fileName, line, msg, logFlags...)
+}
+
+// CustomLog output msg with prefix & color of level once & routes its record to writers accepting level,
+// logFlags are kept for compatibility only
+func (l *Logger) CustomLog(level Level, prefix, fileName string, line int, msg string, logFlags ...FgLogWriter) {
+	if _, ok := level.info(); !ok {
+		level = ERROR
+	}
+
+	logger := l.logger(level)
+	if !logger.enabled.Load() {
+		return
+	}
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+
+	text := fmt.Sprintf("%s[[%s]]%s%s%s:%d: %s",
 		// LogPutColor,
-		boldcolors[level],
+		levelBoldColor(level),
 		prefix,
 		LogEndColor,
-		timeLogFormat(),
+		logger.timeLogFormat(),
 		fileName,
 		line,
 		msg,
+	)
+	rec := Record{
+		Message: msg,
+		File:    fileName,
+		Line:    line,
 	}
 
-	for _, logFlag := range logFlags {
-		switch logFlag {
-		case FgAll:
-			logErr.Printf(args...)
-			logStat.Printf(args...)
-			logDebug.Printf(args...)
-		case FgErr:
-			logErr.Printf(args...)
-		case FgInfo:
-			logStat.Printf(args...)
-		case FgDebug:
-			logDebug.Printf(args...)
-		}
-	}
+	logger.output(0, rec, text, true)
 }